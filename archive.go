@@ -0,0 +1,103 @@
+package flatfile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+)
+
+// ReadZip loads every member of a zip archive whose name matches the
+// glob pattern, in sorted name order, since vendors frequently deliver
+// daily feeds bundled in archives. Each line records its member name
+// and line number as its source. A pattern matching no members is an
+// error.
+func (ff *FlatFile) ReadZip(r io.ReaderAt, size int64, pattern string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	var members []*zip.File
+	for _, member := range zr.File {
+		if member.FileInfo().IsDir() {
+			continue
+		}
+
+		ok, err := path.Match(pattern, member.Name)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			members = append(members, member)
+		}
+	}
+
+	if len(members) == 0 {
+		return fmt.Errorf("flatfile: no archive members match %q", pattern)
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	for _, member := range members {
+		rc, err := member.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", member.Name, err)
+		}
+
+		_, err = ff.readFrom(rc, member.Name)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", member.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadTar loads every member of a tar stream whose name matches the
+// glob pattern, in archive order. Each line records its member name and
+// line number as its source. A pattern matching no members is an error.
+func (ff *FlatFile) ReadTar(r io.Reader, pattern string) error {
+	var (
+		matched bool
+		tr      = tar.NewReader(r)
+	)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		ok, err := path.Match(pattern, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		matched = true
+		if _, err := ff.readFrom(tr, hdr.Name); err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+	}
+
+	if !matched {
+		return fmt.Errorf("flatfile: no archive members match %q", pattern)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package flatfile
+
+import "time"
+
+// Audit operation names.
+const (
+	OpSet     = "set"     // a single field value changed
+	OpAppend  = "append"  // a line was appended
+	OpRemove  = "remove"  // a line was removed
+	OpReplace = "replace" // a line's full content was replaced
+)
+
+// An AuditEntry records one tracked mutation of a flat file.
+type AuditEntry struct {
+	Op    string    // one of OpSet, OpAppend, OpRemove, OpReplace
+	Line  int       // index of the affected line
+	Key   string    // field key, for OpSet only
+	Old   string    // prior value, or prior rendered line
+	New   string    // new value, or new rendered line
+	Actor string    // who made the change
+	Time  time.Time // when the change was made
+}
+
+// EnableAudit turns on change tracking attributed to the given actor.
+// From then on Set, SetValues, SetStr, Append, AppendLines, and Remove
+// are recorded with old and new values, retrievable with AuditLog. Any
+// previous log is cleared.
+func (ff *FlatFile) EnableAudit(actor string) {
+	ff.auditOn = true
+	ff.actor = actor
+	ff.audit = nil
+	ff.redo = nil
+}
+
+// SetActor changes who subsequent tracked changes are attributed to.
+func (ff *FlatFile) SetActor(actor string) {
+	ff.actor = actor
+}
+
+// AuditLog returns a copy of the recorded changes in the order they were
+// made.
+func (ff *FlatFile) AuditLog() []AuditEntry {
+	return append([]AuditEntry(nil), ff.audit...)
+}
+
+// record appends an audit entry if tracking is enabled.
+func (ff *FlatFile) record(op string, line int, key, old, new string) {
+	if !ff.auditOn {
+		return
+	}
+
+	ff.redo = nil
+	ff.audit = append(ff.audit, AuditEntry{
+		Op:    op,
+		Line:  line,
+		Key:   key,
+		Old:   old,
+		New:   new,
+		Actor: ff.actor,
+		Time:  time.Now(),
+	})
+}
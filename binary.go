@@ -0,0 +1,299 @@
+package flatfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Binary snapshot layout versions. ReadSnapshot rejects versions it
+// does not know, so the layout can evolve without silently misreading
+// old caches. Version 2 prefixes the lines with the file-level metadata
+// map; version 3 always carries the metadata map and adds each field's
+// time layout and ragged flag. Files written at older versions still
+// read.
+const (
+	snapshotVersion     = 1
+	snapshotVersionMeta = 2
+	snapshotVersionTime = 3
+)
+
+// WriteSnapshot writes a flat file in a compact length-prefixed binary
+// layout, far faster to persist and reload than JSON, for caching parsed
+// files between pipeline stages. File-level metadata is embedded when
+// present. Hook functions — parse and render hooks, checksum functions,
+// and formatters — cannot be serialized and are dropped.
+func (ff *FlatFile) WriteSnapshot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteByte(snapshotVersionTime)
+	writeUvarint(bw, uint64(len(ff.meta)))
+	for k, v := range ff.meta {
+		writeSnapshotString(bw, k)
+		writeSnapshotString(bw, v)
+	}
+
+	writeUvarint(bw, uint64(len(ff.lines)))
+	for _, ln := range ff.lines {
+		writeUvarint(bw, uint64(ln.length))
+		writeUvarint(bw, uint64(len(ln.fields)))
+		for _, f := range ln.fields {
+			writeSnapshotField(bw, f)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeSnapshotField writes one field's format and value.
+func writeSnapshotField(bw *bufio.Writer, f Field) {
+	writeSnapshotString(bw, f.key)
+	writeUvarint(bw, uint64(f.index))
+	writeUvarint(bw, uint64(f.length))
+	bw.WriteByte(byte(f.jsonType))
+	writeSnapshotString(bw, f.timeLayout)
+	writeBool(bw, f.zeroPad)
+	bw.WriteByte(byte(f.sign))
+	writeUvarint(bw, uint64(len(f.enum)))
+	for code, label := range f.enum {
+		writeSnapshotString(bw, code)
+		writeSnapshotString(bw, label)
+	}
+
+	writeSnapshotString(bw, f.def)
+	writeBool(bw, f.defWrite)
+	writeBool(bw, f.seq)
+	writeUvarint(bw, uint64(f.seqStart))
+	writeUvarint(bw, uint64(len(f.ckOver)))
+	for _, key := range f.ckOver {
+		writeSnapshotString(bw, key)
+	}
+
+	writeBool(bw, f.ragged)
+	writeSnapshotString(bw, f.value)
+}
+
+// ReadSnapshot replaces a flat file's lines with those read from a
+// binary snapshot written by WriteSnapshot.
+func (ff *FlatFile) ReadSnapshot(r io.Reader) error {
+	br := bufio.NewReader(r)
+	version, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	if version < snapshotVersion || snapshotVersionTime < version {
+		return fmt.Errorf("flatfile: unsupported snapshot version %d", version)
+	}
+
+	var meta map[string]string
+	if snapshotVersionMeta <= version {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return err
+		}
+
+		meta = make(map[string]string, n)
+		for ; 0 < n; n-- {
+			k, err := readSnapshotString(br)
+			if err != nil {
+				return err
+			}
+
+			v, err := readSnapshotString(br)
+			if err != nil {
+				return err
+			}
+
+			meta[k] = v
+		}
+
+		if len(meta) == 0 {
+			meta = nil
+		}
+	}
+
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]*Line, 0, n)
+	for ; 0 < n; n-- {
+		ln, err := readSnapshotLine(br, version)
+		if err != nil {
+			return err
+		}
+
+		lines = append(lines, ln)
+	}
+
+	ff.lines = lines
+	ff.meta = meta
+	ff.shared = false
+	return nil
+}
+
+// readSnapshotLine reads one line and rebuilds its key index.
+func readSnapshotLine(br *bufio.Reader, version byte) (*Line, error) {
+	length, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+
+	ln := &Line{
+		fields:     make([]Field, 0, n),
+		keyToIndex: make(map[string]int, n),
+		length:     int(length),
+	}
+
+	for ; 0 < n; n-- {
+		f, err := readSnapshotField(br, version)
+		if err != nil {
+			return nil, err
+		}
+
+		ln.keyToIndex[f.key] = len(ln.fields)
+		ln.fields = append(ln.fields, f)
+	}
+
+	return ln, nil
+}
+
+// readSnapshotField reads one field's format and value.
+func readSnapshotField(br *bufio.Reader, version byte) (Field, error) {
+	var (
+		f   Field
+		err error
+	)
+
+	read := func(fn func() error) {
+		if err == nil {
+			err = fn()
+		}
+	}
+
+	read(func() error { f.key, err = readSnapshotString(br); return err })
+	read(func() error { f.index, err = readInt(br); return err })
+	read(func() error { f.length, err = readInt(br); return err })
+	read(func() error {
+		b, err := br.ReadByte()
+		f.jsonType = JSONType(b)
+		return err
+	})
+	if snapshotVersionTime <= version {
+		read(func() error { f.timeLayout, err = readSnapshotString(br); return err })
+	}
+
+	read(func() error { f.zeroPad, err = readBool(br); return err })
+	read(func() error {
+		b, err := br.ReadByte()
+		f.sign = SignPosition(b)
+		return err
+	})
+	read(func() error {
+		n, err := readInt(br)
+		if err != nil || n == 0 {
+			return err
+		}
+
+		f.enum = make(map[string]string, n)
+		for ; 0 < n; n-- {
+			code, err := readSnapshotString(br)
+			if err != nil {
+				return err
+			}
+
+			label, err := readSnapshotString(br)
+			if err != nil {
+				return err
+			}
+
+			f.enum[code] = label
+		}
+
+		return nil
+	})
+	read(func() error { f.def, err = readSnapshotString(br); return err })
+	read(func() error { f.defWrite, err = readBool(br); return err })
+	read(func() error { f.seq, err = readBool(br); return err })
+	read(func() error { f.seqStart, err = readInt(br); return err })
+	read(func() error {
+		n, err := readInt(br)
+		if err != nil || n == 0 {
+			return err
+		}
+
+		f.ckOver = make([]string, 0, n)
+		for ; 0 < n; n-- {
+			key, err := readSnapshotString(br)
+			if err != nil {
+				return err
+			}
+
+			f.ckOver = append(f.ckOver, key)
+		}
+
+		return nil
+	})
+	if snapshotVersionTime <= version {
+		read(func() error { f.ragged, err = readBool(br); return err })
+	}
+
+	read(func() error { f.value, err = readSnapshotString(br); return err })
+
+	return f, err
+}
+
+// writeUvarint writes n as a uvarint.
+func writeUvarint(bw *bufio.Writer, n uint64) {
+	var b [binary.MaxVarintLen64]byte
+	bw.Write(b[:binary.PutUvarint(b[:], n)])
+}
+
+// writeBool writes a bool as a single byte.
+func writeBool(bw *bufio.Writer, v bool) {
+	if v {
+		bw.WriteByte(1)
+	} else {
+		bw.WriteByte(0)
+	}
+}
+
+// writeSnapshotString writes a length-prefixed string.
+func writeSnapshotString(bw *bufio.Writer, s string) {
+	writeUvarint(bw, uint64(len(s)))
+	bw.WriteString(s)
+}
+
+// readInt reads a uvarint as an int.
+func readInt(br *bufio.Reader) (int, error) {
+	n, err := binary.ReadUvarint(br)
+	return int(n), err
+}
+
+// readBool reads a single-byte bool.
+func readBool(br *bufio.Reader) (bool, error) {
+	b, err := br.ReadByte()
+	return b != 0, err
+}
+
+// readSnapshotString reads a length-prefixed string.
+func readSnapshotString(br *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
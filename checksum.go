@@ -0,0 +1,68 @@
+package flatfile
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+)
+
+// A ChecksumAlgo selects the algorithm used for per-line checksum
+// annotations.
+type ChecksumAlgo byte
+
+const (
+	// CRC32 annotates lines with an IEEE CRC-32 checksum.
+	CRC32 ChecksumAlgo = iota
+	// MD5 annotates lines with an MD5 digest.
+	MD5
+)
+
+// checksumOf returns the hex-encoded checksum of s under the given
+// algorithm.
+func checksumOf(s string, algo ChecksumAlgo) string {
+	switch algo {
+	case MD5:
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	default:
+		return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(s)))
+	}
+}
+
+// Checksum returns a line's checksum annotation, or the empty string if
+// it has not been annotated.
+func (ln *Line) Checksum() string {
+	return ln.checksum
+}
+
+// AnnotateChecksums stamps every line with a checksum of its rendered
+// content. The annotation travels with the line in memory but is not
+// written to the flat output.
+func (ff *FlatFile) AnnotateChecksums(algo ChecksumAlgo) {
+	ff.own()
+
+	for _, ln := range ff.lines {
+		ln.checksumAlgo = algo
+		ln.checksum = checksumOf(ln.String(), algo)
+	}
+}
+
+// VerifyAnnotatedChecksums returns the indices of the lines whose
+// rendered content no longer matches their checksum annotation,
+// detecting corruption introduced between annotation and use. Lines
+// without an annotation are skipped.
+func (ff *FlatFile) VerifyAnnotatedChecksums() []int {
+	var bad []int
+	for i, ln := range ff.lines {
+		if len(ln.checksum) == 0 {
+			continue
+		}
+
+		if checksumOf(ln.String(), ln.checksumAlgo) != ln.checksum {
+			bad = append(bad, i)
+		}
+	}
+
+	return bad
+}
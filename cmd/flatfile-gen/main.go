@@ -0,0 +1,80 @@
+// Flatfile-gen generates typed accessors for a fixed-width layout,
+// keeping generated code in sync with its source schema via go:generate:
+//
+//	//go:generate flatfile-gen -copybook layout.cpy -name Account -o layout_gen.go
+//
+// The layout comes from either a COBOL copybook (-copybook) or a JSON
+// schema (-schema) holding an array of format objects. The package name
+// defaults to GOPACKAGE, which go generate sets.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nathangreene3/flatfile"
+)
+
+func main() {
+	var (
+		copybook = flag.String("copybook", "", "path to a COBOL copybook defining the layout")
+		schema   = flag.String("schema", "", "path to a JSON schema defining the layout")
+		pkg      = flag.String("pkg", os.Getenv("GOPACKAGE"), "package name for the generated file")
+		name     = flag.String("name", "", "name of the generated struct")
+		out      = flag.String("o", "", "output path; defaults to stdout")
+	)
+
+	flag.Parse()
+
+	if err := run(*copybook, *schema, *pkg, *name, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "flatfile-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(copybook, schema, pkg, name, out string) error {
+	if (len(copybook) == 0) == (len(schema) == 0) {
+		return fmt.Errorf("exactly one of -copybook and -schema is required")
+	}
+
+	if len(pkg) == 0 || len(name) == 0 {
+		return fmt.Errorf("-pkg (or GOPACKAGE) and -name are required")
+	}
+
+	var layout []flatfile.Format
+	if 0 < len(copybook) {
+		file, err := os.Open(copybook)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		layout, err = flatfile.ParseCopybook(file)
+		if err != nil {
+			return err
+		}
+	} else {
+		b, err := os.ReadFile(schema)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(b, &layout); err != nil {
+			return err
+		}
+	}
+
+	src, err := flatfile.Generate(pkg, name, layout)
+	if err != nil {
+		return err
+	}
+
+	if len(out) == 0 {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+
+	return os.WriteFile(out, src, 0o644)
+}
@@ -0,0 +1,88 @@
+package flatfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Column is one typed column of a record batch. Exactly one of the
+// value slices is populated, chosen by Type; Valid marks the rows that
+// held a value, with blanks exported as nulls.
+type Column struct {
+	Key     string
+	Type    JSONType
+	Strings []string  // populated when Type is Str
+	Numbers []float64 // populated when Type is Num
+	Bools   []bool    // populated when Type is Bool
+	Valid   []bool
+}
+
+// A RecordBatch is a flat file pivoted into typed columns, the shape
+// Arrow-based analytics consume. The value slices are laid out exactly
+// as Arrow builders expect, so an adapter can hand them to an Arrow
+// library without this package taking the dependency.
+type RecordBatch struct {
+	NumRows int
+	Columns []Column
+}
+
+// RecordBatch pivots a flat file into typed columns under the first
+// line's layout. Rows missing a column's key, and blank values, are
+// null. Num values must parse as floats and Bool values as booleans.
+func (ff *FlatFile) RecordBatch() (*RecordBatch, error) {
+	batch := &RecordBatch{NumRows: len(ff.lines)}
+	if len(ff.lines) == 0 {
+		return batch, nil
+	}
+
+	for _, f := range ff.lines[0].fields {
+		col := Column{
+			Key:   f.key,
+			Type:  f.jsonType,
+			Valid: make([]bool, len(ff.lines)),
+		}
+
+		switch f.jsonType {
+		case Num:
+			col.Numbers = make([]float64, len(ff.lines))
+		case Bool:
+			col.Bools = make([]bool, len(ff.lines))
+		default:
+			col.Strings = make([]string, len(ff.lines))
+		}
+
+		for i, ln := range ff.lines {
+			j, ok := ln.keyToIndex[f.key]
+			if !ok || len(ln.fields[j].value) == 0 {
+				continue
+			}
+
+			v := ln.fields[j].value
+			switch f.jsonType {
+			case Num:
+				n, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: field %q: %w", i, f.key, err)
+				}
+
+				col.Numbers[i] = n
+			case Bool:
+				b, err := strconv.ParseBool(strings.ToLower(v))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: field %q: %w", i, f.key, err)
+				}
+
+				col.Bools[i] = b
+			default:
+				col.Strings[i] = v
+			}
+
+			col.Valid[i] = true
+		}
+
+		batch.Columns = append(batch.Columns, col)
+	}
+
+	return batch, nil
+}
@@ -0,0 +1,110 @@
+package flatfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseCopybook parses a COBOL copybook into a layout, so mainframe
+// record definitions can drive the generator directly. Elementary items
+// with PIC X clauses become Str fields and PIC 9 clauses Num fields,
+// laid out contiguously in declaration order; group items, which carry
+// no PIC clause, only contribute their children. Names are lowercased
+// with dashes mapped to underscores.
+func ParseCopybook(r io.Reader) ([]Format, error) {
+	var (
+		layout []Format
+		index  int
+		sc     = bufio.NewScanner(r)
+	)
+
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(sc.Text()), "."))
+		if len(line) == 0 || strings.HasPrefix(line, "*") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("flatfile: copybook line %d: expected a level number and a name", lineNo)
+		}
+
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			return nil, fmt.Errorf("flatfile: copybook line %d: invalid level number %q", lineNo, fields[0])
+		}
+
+		pic := -1
+		for i, w := range fields {
+			if strings.EqualFold(w, "PIC") || strings.EqualFold(w, "PICTURE") {
+				pic = i
+			}
+		}
+
+		if pic < 0 {
+			continue
+		}
+
+		if len(fields) <= pic+1 {
+			return nil, fmt.Errorf("flatfile: copybook line %d: PIC clause without a picture", lineNo)
+		}
+
+		jsonType, length, err := parsePicture(fields[pic+1])
+		if err != nil {
+			return nil, fmt.Errorf("flatfile: copybook line %d: %w", lineNo, err)
+		}
+
+		key := strings.ReplaceAll(strings.ToLower(fields[1]), "-", "_")
+		layout = append(layout, NewFormat(key, index, length, jsonType))
+		index += length
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return layout, nil
+}
+
+// parsePicture reads a picture such as X(8), 9(3), XXX, or 999,
+// returning the field's type and width.
+func parsePicture(pic string) (JSONType, int, error) {
+	if len(pic) == 0 {
+		return Str, 0, fmt.Errorf("empty picture")
+	}
+
+	var jsonType JSONType
+	switch pic[0] {
+	case 'X', 'x':
+		jsonType = Str
+	case '9':
+		jsonType = Num
+	default:
+		return Str, 0, fmt.Errorf("unsupported picture %q", pic)
+	}
+
+	// A repeat count, as in X(8), or repetition, as in XXX.
+	if i := strings.IndexByte(pic, '('); 0 < i {
+		j := strings.IndexByte(pic, ')')
+		if j < i {
+			return Str, 0, fmt.Errorf("unbalanced picture %q", pic)
+		}
+
+		n, err := strconv.Atoi(pic[i+1 : j])
+		if err != nil || n < 1 {
+			return Str, 0, fmt.Errorf("invalid repeat count in picture %q", pic)
+		}
+
+		return jsonType, n, nil
+	}
+
+	for i := 1; i < len(pic); i++ {
+		if pic[i] != pic[0] {
+			return Str, 0, fmt.Errorf("unsupported picture %q", pic)
+		}
+	}
+
+	return jsonType, len(pic), nil
+}
@@ -0,0 +1,55 @@
+package flatfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DDL renders a layout as a CREATE TABLE statement, so a feed's staging
+// table comes from the same source of truth as its parser. Str fields
+// map to CHAR of the format length, Num to NUMERIC, Bool to BOOLEAN,
+// Timestamp to TIMESTAMP, and Object and Array to the dialect's JSON
+// type; columns follow layout order and keys are sanitized like proto
+// identifiers. Supported dialects are "postgres" and "mysql"; anything
+// else gets portable ANSI types.
+func DDL(table string, layout []Format, dialect string) string {
+	var sb strings.Builder
+	sb.WriteString("CREATE TABLE " + table + " (\n")
+	for i, f := range layout {
+		if 0 < i {
+			sb.WriteString(",\n")
+		}
+
+		fmt.Fprintf(&sb, "  %s %s", protoName(f.key), ddlType(f, dialect))
+	}
+
+	sb.WriteString("\n);\n")
+	return sb.String()
+}
+
+// ddlType maps a format to a column type in the given dialect.
+func ddlType(f Format, dialect string) string {
+	switch f.jsonType {
+	case Num:
+		if dialect == "mysql" {
+			return fmt.Sprintf("DECIMAL(%d)", f.length)
+		}
+
+		return fmt.Sprintf("NUMERIC(%d)", f.length)
+	case Bool:
+		return "BOOLEAN"
+	case Timestamp:
+		return "TIMESTAMP"
+	case Object, Array:
+		switch dialect {
+		case "postgres":
+			return "JSONB"
+		case "mysql":
+			return "JSON"
+		default:
+			return fmt.Sprintf("VARCHAR(%d)", f.length)
+		}
+	default:
+		return fmt.Sprintf("CHAR(%d)", f.length)
+	}
+}
@@ -0,0 +1,85 @@
+package flatfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DecodeJSON decodes a JSON flat file — either form MarshalJSON emits —
+// from a reader one line at a time, replacing any existing lines and
+// metadata. Unlike UnmarshalJSON it never holds the whole payload in
+// memory, so arbitrarily large arrays decode in bounded space.
+func (ff *FlatFile) DecodeJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	var (
+		lines []*Line
+		meta  map[string]string
+	)
+
+	switch tok {
+	case json.Delim('['):
+		if lines, err = decodeJSONLines(dec); err != nil {
+			return err
+		}
+
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			switch key {
+			case "meta":
+				if err := dec.Decode(&meta); err != nil {
+					return err
+				}
+
+			case "lines":
+				if tok, err := dec.Token(); err != nil {
+					return err
+				} else if tok != json.Delim('[') {
+					return fmt.Errorf("flatfile: expected a JSON array of lines, got %v", tok)
+				}
+
+				if lines, err = decodeJSONLines(dec); err != nil {
+					return err
+				}
+
+			default:
+				return fmt.Errorf("flatfile: unexpected key %v", key)
+			}
+		}
+
+	default:
+		return fmt.Errorf("flatfile: expected a JSON array or object, got %v", tok)
+	}
+
+	ff.lines = lines
+	ff.meta = meta
+	ff.shared = false
+	return nil
+}
+
+// decodeJSONLines decodes lines until the open array the decoder is
+// positioned inside closes.
+func decodeJSONLines(dec *json.Decoder) ([]*Line, error) {
+	var lines []*Line
+	for dec.More() {
+		ln := &Line{}
+		if err := dec.Decode(ln); err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, ln)
+	}
+
+	_, err := dec.Token()
+	return lines, err
+}
@@ -0,0 +1,174 @@
+package flatfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A FieldChange records one field's value before and after a change.
+type FieldChange struct {
+	Key string
+	Old string
+	New string
+}
+
+// A LineDiff identifies a changed record by its key-field values and
+// lists the fields that changed.
+type LineDiff struct {
+	Key     []string
+	Changes []FieldChange
+}
+
+// A DiffReport summarizes the differences between two flat files: lines
+// added, lines removed, and lines whose fields changed, with records
+// matched by the values of the key fields.
+type DiffReport struct {
+	KeyFields []string
+	Added     []*Line
+	Removed   []*Line
+	Changed   []LineDiff
+}
+
+// Empty reports whether a diff report contains no differences.
+func (rep DiffReport) Empty() bool {
+	return len(rep.Added) == 0 && len(rep.Removed) == 0 && len(rep.Changed) == 0
+}
+
+// lineKey returns the values of the given key fields joined into a
+// single map key. With no key fields, the full rendered line is the key.
+func lineKey(ln *Line, keyFields []string) string {
+	if len(keyFields) == 0 {
+		return ln.String()
+	}
+
+	values := make([]string, 0, len(keyFields))
+	for _, key := range keyFields {
+		v, _ := ln.Get(key)
+		values = append(values, v)
+	}
+
+	return strings.Join(values, "\x1f")
+}
+
+// Diff returns the changes that transform the receiver into other.
+// Records are matched by the values of the given key fields, which are
+// assumed unique within each file; with no key fields, lines are matched
+// by their full rendered content and only appear as added or removed.
+func (ff *FlatFile) Diff(other *FlatFile, keyFields ...string) DiffReport {
+	rep := DiffReport{KeyFields: keyFields}
+
+	oldByKey := make(map[string]*Line, len(ff.lines))
+	for _, ln := range ff.lines {
+		oldByKey[lineKey(ln, keyFields)] = ln
+	}
+
+	newKeys := make(map[string]bool, len(other.lines))
+	for _, ln := range other.lines {
+		key := lineKey(ln, keyFields)
+		newKeys[key] = true
+
+		old, ok := oldByKey[key]
+		if !ok {
+			rep.Added = append(rep.Added, ln.Copy())
+			continue
+		}
+
+		var changes []FieldChange
+		for _, f := range ln.fields {
+			oldValue, _ := old.Get(f.key)
+			if oldValue != f.value {
+				changes = append(changes, FieldChange{Key: f.key, Old: oldValue, New: f.value})
+			}
+		}
+
+		if 0 < len(changes) {
+			rep.Changed = append(rep.Changed, LineDiff{Key: strings.Split(key, "\x1f"), Changes: changes})
+		}
+	}
+
+	for _, ln := range ff.lines {
+		if !newKeys[lineKey(ln, keyFields)] {
+			rep.Removed = append(rep.Removed, ln.Copy())
+		}
+	}
+
+	return rep
+}
+
+// Apply transforms the receiver by a diff report produced by Diff:
+// removed lines are dropped, changed fields are updated, and added lines
+// are appended. It returns an error if a removed or changed record
+// cannot be found by its key fields, or if an updated value does not fit
+// its field.
+func (ff *FlatFile) Apply(patch DiffReport) error {
+	ff.own()
+
+	byKey := make(map[string]int, len(ff.lines))
+	for i, ln := range ff.lines {
+		byKey[lineKey(ln, patch.KeyFields)] = i
+	}
+
+	for _, ld := range patch.Changed {
+		key := strings.Join(ld.Key, "\x1f")
+		i, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("flatfile: no record with key %q to change", strings.Join(ld.Key, ","))
+		}
+
+		for _, c := range ld.Changes {
+			if err := ff.lines[i].Set(c.Key, c.New); err != nil {
+				return err
+			}
+		}
+	}
+
+	removed := make(map[string]bool, len(patch.Removed))
+	for _, ln := range patch.Removed {
+		key := lineKey(ln, patch.KeyFields)
+		if _, ok := byKey[key]; !ok {
+			return fmt.Errorf("flatfile: no record with key %q to remove", key)
+		}
+
+		removed[key] = true
+	}
+
+	if 0 < len(removed) {
+		kept := ff.lines[:0]
+		for _, ln := range ff.lines {
+			if !removed[lineKey(ln, patch.KeyFields)] {
+				kept = append(kept, ln)
+			}
+		}
+
+		ff.lines = kept
+	}
+
+	for _, ln := range patch.Added {
+		ff.lines = append(ff.lines, ln.Copy())
+	}
+
+	return nil
+}
+
+// String returns a human-readable summary of a diff report.
+func (rep DiffReport) String() string {
+	var sb strings.Builder
+	for _, ln := range rep.Added {
+		fmt.Fprintf(&sb, "+ %s\n", ln.String())
+	}
+
+	for _, ln := range rep.Removed {
+		fmt.Fprintf(&sb, "- %s\n", ln.String())
+	}
+
+	for _, ld := range rep.Changed {
+		fmt.Fprintf(&sb, "~ %s:", strings.Join(ld.Key, ","))
+		for _, c := range ld.Changes {
+			fmt.Fprintf(&sb, " %s %q -> %q", c.Key, c.Old, c.New)
+		}
+
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
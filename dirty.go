@@ -0,0 +1,72 @@
+package flatfile
+
+import "io"
+
+// Dirty returns the indexes of lines that have changed since the last
+// full write, in line order.
+func (ff *FlatFile) Dirty() []int {
+	var dirty []int
+	for i, ln := range ff.lines {
+		if ln.dirty {
+			dirty = append(dirty, i)
+		}
+	}
+
+	return dirty
+}
+
+// WriteDirty rewrites only the changed lines of a previously written
+// file, in place at their byte offsets, so small edits to huge files do
+// not force full rewrites. Appended lines extend the file. A configured
+// record length is enforced on the rewritten lines as in WriteTo, and
+// fixes every line's on-disk width, so the offsets stay aligned with
+// the last full write. It assumes the order and rendered widths of the
+// unchanged lines have not moved since the last full write; after
+// reordering or width-changing edits, write the whole file instead.
+// Lines written successfully are marked clean.
+func (ff *FlatFile) WriteDirty(w io.WriterAt) error {
+	var off int64
+	for i, ln := range ff.lines {
+		if ln.dirty {
+			s, err := ff.enforceWidth(ln.String(), i)
+			if err != nil {
+				return err
+			}
+
+			if _, err := w.WriteAt([]byte(s+"\n"), off); err != nil {
+				return err
+			}
+
+			ln.dirty = false
+			off += int64(len(s)) + 1
+			continue
+		}
+
+		if 0 < ff.recordLen {
+			off += int64(ff.recordLen) + 1
+		} else {
+			off += int64(ln.ByteLen()) + 1
+		}
+	}
+
+	return nil
+}
+
+// markClean clears every line's dirty flag after a full write or a fresh
+// load. Lines still shared with a snapshot are copied first, so writing
+// one side never clears flags the other side still needs.
+func (ff *FlatFile) markClean() {
+	if ff.shared {
+		lines := make([]*Line, len(ff.lines))
+		for i, ln := range ff.lines {
+			lines[i] = ln.Copy()
+		}
+
+		ff.lines = lines
+		ff.shared = false
+	}
+
+	for _, ln := range ff.lines {
+		ln.dirty = false
+	}
+}
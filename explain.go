@@ -0,0 +1,71 @@
+package flatfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExplainLine renders a raw line with numbered column markers, the field
+// spans the formatter assigns bracketed underneath, and every parsed
+// value, so questions like "why did this field come back empty?" can be
+// answered by looking at the layout against the actual bytes. Formats
+// that overrun the line are called out rather than parsed.
+func ExplainLine(line string, fmtr Formatter) string {
+	lf := fmtr(line)
+	width := len(line)
+	for _, f := range lf {
+		if width < f.index+f.length {
+			width = f.index + f.length
+		}
+	}
+
+	var sb strings.Builder
+	if 10 < width {
+		for i := 0; i < width; i++ {
+			sb.WriteByte('0' + byte(i/10%10))
+		}
+
+		sb.WriteByte('\n')
+	}
+
+	for i := 0; i < width; i++ {
+		sb.WriteByte('0' + byte(i%10))
+	}
+
+	sb.WriteString("\n" + line + "\n")
+
+	spans := []byte(strings.Repeat(" ", width))
+	for _, f := range lf {
+		if f.length == 0 {
+			continue
+		}
+
+		spans[f.index] = '['
+		spans[f.index+f.length-1] = ']'
+		for i, j := 0, f.index+1; i < len(f.key) && j < f.index+f.length-1; i, j = i+1, j+1 {
+			spans[j] = f.key[i]
+		}
+	}
+
+	sb.Write(spans)
+	sb.WriteByte('\n')
+
+	keyWidth := 0
+	for _, f := range lf {
+		if keyWidth < len(f.key) {
+			keyWidth = len(f.key)
+		}
+	}
+
+	for _, f := range lf {
+		fmt.Fprintf(&sb, "%-*s [%d:%d]", keyWidth, f.key, f.index, f.index+f.length)
+		if len(line) < f.index+f.length {
+			fmt.Fprintf(&sb, " overruns the line (length %d)\n", len(line))
+			continue
+		}
+
+		fmt.Fprintf(&sb, " = %q\n", f.parseValue(line[f.index:f.index+f.length]))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+package flatfile
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// GenerateFake returns a flat file of n synthetic lines under the given
+// layout, for load tests and demos without touching production data.
+// Values are deterministic for a given seed and respect each field's
+// shape: Str fields get pronounceable lowercase words, Num fields get
+// numbers fitting the width, Bool fields get booleans, enum fields draw
+// from their codes, and sequence fields number themselves as usual.
+func GenerateFake(layout []Format, n int, seed int64) *FlatFile {
+	rng := rand.New(rand.NewSource(seed))
+	ff := New(StaticFormatter(layout...))
+	for i := 0; i < n; i++ {
+		fields := make([]Field, 0, len(layout))
+		for _, f := range layout {
+			fields = append(fields, NewField(f, fakeValue(f, rng)))
+		}
+
+		ln := newLineFromFields(fields)
+		ln.fillSequences(i)
+		ff.lines = append(ff.lines, ln)
+	}
+
+	return ff
+}
+
+// fakeValue draws one plausible value for a format.
+func fakeValue(f Format, rng *rand.Rand) string {
+	if f.seq {
+		return ""
+	}
+
+	if 0 < len(f.enum) {
+		codes := make([]string, 0, len(f.enum))
+		for code := range f.enum {
+			codes = append(codes, code)
+		}
+
+		sort.Strings(codes)
+		return codes[rng.Intn(len(codes))]
+	}
+
+	switch f.jsonType {
+	case Num:
+		digits := f.length
+		if 9 < digits {
+			digits = 9
+		}
+
+		bound := 1
+		for i := 0; i < digits; i++ {
+			bound *= 10
+		}
+
+		return strconv.Itoa(rng.Intn(bound))
+	case Bool:
+		v := rng.Intn(2) == 0
+		switch {
+		case 5 <= f.length:
+			return strconv.FormatBool(v)
+		case v:
+			return "1"
+		default:
+			return "0"
+		}
+	default:
+		return fakeWord(f.length, rng)
+	}
+}
+
+// fakeWord builds a pronounceable lowercase word of at most width bytes
+// by alternating consonants and vowels.
+func fakeWord(width int, rng *rand.Rand) string {
+	const (
+		consonants = "bcdfghjklmnprstvw"
+		vowels     = "aeiou"
+	)
+
+	n := width
+	if 3 < n {
+		n = 3 + rng.Intn(width-2)
+	}
+
+	b := make([]byte, 0, n)
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			b = append(b, consonants[rng.Intn(len(consonants))])
+		} else {
+			b = append(b, vowels[rng.Intn(len(vowels))])
+		}
+	}
+
+	return string(b)
+}
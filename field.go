@@ -0,0 +1,246 @@
+package flatfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Field is a parsed value paired with the format that produced it.
+type Field struct {
+	Format
+	value string
+}
+
+// NewField returns a field holding the given value under the given
+// format.
+func NewField(f Format, value string) Field {
+	return Field{
+		Format: f,
+		value:  value,
+	}
+}
+
+// Value returns a field's parsed value.
+func (f Field) Value() string {
+	return f.value
+}
+
+// String returns a field's value left justified and padded with spaces
+// to the format length, passed through the format's render hook when one
+// is set.
+func (f Field) String() string {
+	v := f.value
+	if 0 < len(f.def) && !f.defWrite && v == f.def {
+		v = ""
+	}
+
+	if f.render != nil {
+		v = f.render(v)
+	}
+
+	if f.sign != SignNone {
+		sign := " "
+		if strings.HasPrefix(v, "-") {
+			sign = "-"
+			v = v[1:]
+		}
+
+		v = pad(v, f.length-1, f.zeroPad)
+		if f.sign == SignTrailing {
+			return v + sign
+		}
+
+		return sign + v
+	}
+
+	return pad(v, f.length, f.zeroPad)
+}
+
+// pad fits a value to width: right justified and zero filled when zero
+// is set, left justified and space filled otherwise.
+func pad(v string, width int, zero bool) string {
+	if width <= len(v) {
+		return v
+	}
+
+	if zero {
+		return strings.Repeat("0", width-len(v)) + v
+	}
+
+	return v + strings.Repeat(" ", width-len(v))
+}
+
+// A FieldMarshaler renders a field's exported value, overriding the
+// json type rules when a format carries one through WithMarshaler. The
+// returned bytes embed verbatim when they are valid JSON and are quoted
+// as a string otherwise, so a marshaler may return either raw JSON or a
+// plain label.
+type FieldMarshaler interface {
+	MarshalField(f Field) ([]byte, error)
+}
+
+// A FieldMarshalerFunc adapts a function into a FieldMarshaler.
+type FieldMarshalerFunc func(f Field) ([]byte, error)
+
+// MarshalField calls the adapted function.
+func (fn FieldMarshalerFunc) MarshalField(f Field) ([]byte, error) {
+	return fn(f)
+}
+
+// MarshalJSON returns a field as a JSON object. The value is quoted,
+// numeric, or boolean according to the field's json type.
+func (f Field) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// appendJSON streams a field's JSON object into a buffer, so marshaling
+// a flat file fills one buffer rather than concatenating strings.
+func (f Field) appendJSON(buf *bytes.Buffer) error {
+	buf.WriteString(`{"key":`)
+	buf.WriteString(strconv.Quote(f.key))
+	buf.WriteString(`,"value":`)
+	if err := f.appendJSONValue(buf); err != nil {
+		return err
+	}
+
+	buf.WriteString(`,"index":`)
+	buf.WriteString(strconv.Itoa(f.index))
+	buf.WriteString(`,"length":`)
+	buf.WriteString(strconv.Itoa(f.length))
+	buf.WriteString(`,"jsonType":`)
+	buf.WriteString(strconv.Quote(f.jsonType.String()))
+	if 0 < len(f.timeLayout) {
+		buf.WriteString(`,"timeLayout":`)
+		buf.WriteString(strconv.Quote(f.timeLayout))
+	}
+
+	buf.WriteByte('}')
+	return nil
+}
+
+// appendJSONValue streams a field's value quoted, numeric, or boolean
+// according to its json type, null when a typed value is blank. Object
+// and Array values are embedded verbatim when they are valid JSON and
+// quoted like strings otherwise, keeping the output well formed. A
+// marshaler set through WithMarshaler overrides all of this.
+func (f Field) appendJSONValue(buf *bytes.Buffer) error {
+	if f.marshaler != nil {
+		b, err := f.marshaler.MarshalField(f)
+		if err != nil {
+			return fmt.Errorf("flatfile: marshaling field %q: %w", f.key, err)
+		}
+
+		if json.Valid(b) {
+			buf.Write(b)
+		} else {
+			buf.WriteString(strconv.Quote(string(b)))
+		}
+
+		return nil
+	}
+
+	switch f.jsonType {
+	case Num, Bool:
+		if v := strings.TrimSpace(f.value); 0 < len(v) {
+			buf.WriteString(v)
+		} else {
+			buf.WriteString("null")
+		}
+	case Object, Array:
+		v := strings.TrimSpace(f.value)
+		switch {
+		case len(v) == 0:
+			buf.WriteString("null")
+		case json.Valid([]byte(v)):
+			buf.WriteString(v)
+		default:
+			buf.WriteString(strconv.Quote(f.value))
+		}
+	case Timestamp:
+		v := strings.TrimSpace(f.value)
+		switch t, err := time.Parse(f.timeLayout, v); {
+		case len(v) == 0:
+			buf.WriteString("null")
+		case err != nil:
+			buf.WriteString(strconv.Quote(f.value))
+		default:
+			buf.WriteString(strconv.Quote(t.Format(time.RFC3339)))
+		}
+	default:
+		buf.WriteString(strconv.Quote(f.value))
+	}
+
+	return nil
+}
+
+// UnmarshalJSON parses a field from a JSON object, reading the value
+// according to the field's json type so numeric and boolean fields
+// round-trip instead of unmarshaling as empty.
+func (f *Field) UnmarshalJSON(b []byte) error {
+	var aux struct {
+		Key        string          `json:"key"`
+		Value      json.RawMessage `json:"value"`
+		Index      int             `json:"index"`
+		Length     int             `json:"length"`
+		JSONType   string          `json:"jsonType"`
+		TimeLayout string          `json:"timeLayout"`
+	}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	f.key = aux.Key
+	f.index = aux.Index
+	f.length = aux.Length
+	f.jsonType = parseJSONType(aux.JSONType)
+	f.timeLayout = aux.TimeLayout
+	value, err := decodeJSONValue(aux.Value, f.jsonType, f.timeLayout)
+	if err != nil {
+		return err
+	}
+
+	f.value = value
+	return nil
+}
+
+// decodeJSONValue reads a marshaled value back into its flat file form:
+// quoted values unquote, null and absent values are blank, numbers,
+// booleans, and embedded JSON keep their raw text, and timestamps
+// convert from RFC 3339 back to the field's native layout.
+func decodeJSONValue(raw json.RawMessage, jt JSONType, layout string) (string, error) {
+	s := strings.TrimSpace(string(raw))
+	switch {
+	case len(s) == 0, s == "null":
+		return "", nil
+	case s[0] == '"':
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", err
+		}
+
+		if jt == Timestamp && 0 < len(layout) {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t.Format(layout), nil
+			}
+		}
+
+		return v, nil
+	}
+
+	switch jt {
+	case Num, Bool, Object, Array:
+		return s, nil
+	default:
+		return "", fmt.Errorf("flatfile: cannot decode %s as a string", s)
+	}
+}
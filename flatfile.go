@@ -0,0 +1,513 @@
+package flatfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// A FlatFile is an ordered collection of lines sharing a formatter.
+type FlatFile struct {
+	fmtr        Formatter
+	lines       []*Line
+	shared      bool
+	indexes     map[string]map[string][]int
+	trailerFmt  LineFmt
+	trailerFill func(stats FileStats) map[string]string
+	reject      io.Writer
+	tabWidth    int
+	recordLen   int
+	widthPolicy WidthPolicy
+	widthMode   WidthMode
+	meta        map[string]string
+	auditOn     bool
+	actor       string
+	audit       []AuditEntry
+	redo        []AuditEntry
+}
+
+// New returns an empty flat file that parses lines with the given
+// formatter.
+func New(fmtr Formatter) *FlatFile {
+	return &FlatFile{fmtr: fmtr}
+}
+
+// Append parses each raw line with the flat file's formatter and appends
+// the result, expanding tabs first when a tab width is configured.
+func (ff *FlatFile) Append(lines ...string) {
+	ff.own()
+
+	for _, line := range lines {
+		line = expandTabs(line, ff.tabWidth)
+		ln := NewLine(ff.fmtr(line), line)
+		ln.widthMode = ff.widthMode
+		ln.fillSequences(len(ff.lines))
+		ln.dirty = true
+		ff.lines = append(ff.lines, ln)
+		ff.record(OpAppend, len(ff.lines)-1, "", "", ln.String())
+	}
+}
+
+// VerifyChecksums confirms every line's checksum fields against the
+// values recomputed from the fields they cover.
+func (ff *FlatFile) VerifyChecksums() error {
+	for i, ln := range ff.lines {
+		if err := ln.VerifyChecksums(); err != nil {
+			return fmt.Errorf("line %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Resequence renumbers every sequence field from its declared start, in
+// line order, after lines have been reordered, inserted, or removed.
+func (ff *FlatFile) Resequence() {
+	ff.own()
+
+	for i, ln := range ff.lines {
+		ln.fillSequences(i)
+	}
+}
+
+// AppendLines appends already parsed lines.
+func (ff *FlatFile) AppendLines(lns ...*Line) {
+	ff.own()
+
+	for _, ln := range lns {
+		ln.dirty = true
+		ff.lines = append(ff.lines, ln)
+		ff.record(OpAppend, len(ff.lines)-1, "", "", ln.String())
+	}
+}
+
+// Concat re-renders each of another flat file's lines, parses them under
+// the receiver's formatter, and appends the results. If any line is too
+// short for the format the receiver assigns it, Concat returns an error
+// and leaves the receiver unchanged.
+func (ff *FlatFile) Concat(other *FlatFile) error {
+	lns := make([]*Line, 0, len(other.lines))
+	for i, ln := range other.lines {
+		raw := ln.String()
+		lf := ff.fmtr(raw)
+		for _, f := range lf {
+			if !f.fits(len(raw)) {
+				return fmt.Errorf("flatfile: line %d is too short for field %q", i, f.key)
+			}
+		}
+
+		lns = append(lns, NewLine(lf, raw))
+	}
+
+	ff.AppendLines(lns...)
+	return nil
+}
+
+// Len returns the number of lines in a flat file.
+func (ff *FlatFile) Len() int {
+	return len(ff.lines)
+}
+
+// Line returns a copy of the ith line.
+func (ff *FlatFile) Line(i int) *Line {
+	return ff.lines[i].Copy()
+}
+
+// Get returns the value of the field having the given key in the ith
+// line.
+func (ff *FlatFile) Get(i int, key string) (string, error) {
+	return ff.lines[i].Get(key)
+}
+
+// Set updates the value of the field having the given key in the ith
+// line.
+func (ff *FlatFile) Set(i int, key, value string) error {
+	ff.own()
+
+	old, _ := ff.lines[i].Get(key)
+	if err := ff.lines[i].Set(key, value); err != nil {
+		return err
+	}
+
+	ff.record(OpSet, i, key, old, value)
+	return nil
+}
+
+// SetValues applies several field updates to the ith line at once with
+// all-or-nothing semantics: every key must exist and every value must
+// fit its field, or the line is not modified.
+func (ff *FlatFile) SetValues(i int, m map[string]string) error {
+	ff.own()
+
+	olds := make(map[string]string, len(m))
+	for key := range m {
+		olds[key], _ = ff.lines[i].Get(key)
+	}
+
+	if err := ff.lines[i].SetValues(m); err != nil {
+		return err
+	}
+
+	for key, value := range m {
+		ff.record(OpSet, i, key, olds[key], value)
+	}
+
+	return nil
+}
+
+// SetStr replaces the ith line by parsing the given raw text with the
+// flat file's formatter, so corrections arriving as full records can be
+// applied directly. It errors without modifying the line if the text is
+// too short for the format it is assigned.
+func (ff *FlatFile) SetStr(i int, line string) error {
+	lf := ff.fmtr(line)
+	for _, f := range lf {
+		if !f.fits(len(line)) {
+			return fmt.Errorf("flatfile: text of length %d is too short for field %q", len(line), f.key)
+		}
+	}
+
+	ff.own()
+	old := ff.lines[i].String()
+	ff.lines[i] = NewLine(lf, line)
+	ff.lines[i].dirty = true
+	ff.record(OpReplace, i, "", old, line)
+	return nil
+}
+
+// KeyValues returns a map of each field's key to its value in the ith
+// line.
+func (ff *FlatFile) KeyValues(i int) map[string]string {
+	return ff.lines[i].KeyValues()
+}
+
+// Equal reports whether two flat files hold equal lines in the same
+// order. It compares parsed fields, not rendered strings, so files that
+// differ only in trailing padding may still compare unequal.
+func (ff *FlatFile) Equal(other *FlatFile) bool {
+	if len(ff.lines) != len(other.lines) {
+		return false
+	}
+
+	for i, ln := range ff.lines {
+		if !ln.Equal(other.lines[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// InsertAt inserts lines immediately before the ith line, so the first
+// inserted line becomes line i. Inserting at Len appends.
+func (ff *FlatFile) InsertAt(i int, lns ...*Line) {
+	ff.own()
+
+	ff.lines = slices.Insert(ff.lines, i, lns...)
+}
+
+// Splice replaces lines i through j-1 with the given replacement lines,
+// which need not be the same in number.
+func (ff *FlatFile) Splice(i, j int, repl ...*Line) {
+	ff.own()
+
+	ff.lines = slices.Replace(ff.lines, i, j, repl...)
+}
+
+// Remove removes and returns the ith line.
+func (ff *FlatFile) Remove(i int) *Line {
+	ff.own()
+
+	ln := ff.lines[i]
+	ff.lines = append(ff.lines[:i], ff.lines[i+1:]...)
+	ff.record(OpRemove, i, "", ln.String(), "")
+	return ln
+}
+
+// Swap exchanges the ith and jth lines.
+func (ff *FlatFile) Swap(i, j int) {
+	ff.own()
+
+	ff.lines[i], ff.lines[j] = ff.lines[j], ff.lines[i]
+}
+
+// Move removes the line at from and reinserts it so that it becomes the
+// line at to, shifting the lines between them.
+func (ff *FlatFile) Move(from, to int) {
+	ff.own()
+
+	ln := ff.lines[from]
+	ff.lines = slices.Insert(slices.Delete(ff.lines, from, from+1), to, ln)
+}
+
+// Truncate drops every line at index n and beyond. Truncating to a
+// length at or above Len leaves the flat file unchanged; a negative n
+// is treated as zero.
+func (ff *FlatFile) Truncate(n int) {
+	if n < 0 {
+		n = 0
+	}
+
+	if len(ff.lines) <= n {
+		return
+	}
+
+	ff.own()
+	ff.lines = ff.lines[:n:n]
+}
+
+// Reverse flips the order of a flat file's lines in place.
+func (ff *FlatFile) Reverse() {
+	ff.own()
+
+	slices.Reverse(ff.lines)
+}
+
+// Sort sorts the lines of a flat file by the given less function.
+func (ff *FlatFile) Sort(less func(ln0, ln1 *Line) bool) {
+	ff.own()
+
+	sort.Slice(ff.lines, func(i, j int) bool { return less(ff.lines[i], ff.lines[j]) })
+}
+
+// ReadFrom reads and appends each non-empty line from a reader,
+// recording each line's 1-based position as its source. It returns the
+// number of bytes read.
+func (ff *FlatFile) ReadFrom(r io.Reader) (int64, error) {
+	return ff.readFrom(r, "")
+}
+
+// readFrom reads and appends each non-empty line from a reader, stamping
+// each with the given source name and its 1-based line number.
+func (ff *FlatFile) readFrom(r io.Reader, name string) (int64, error) {
+	var (
+		n      int64
+		lineNo int
+		sc     = bufio.NewScanner(r)
+	)
+
+	for sc.Scan() {
+		lineNo++
+		line := sc.Text()
+		n += int64(len(line)) + 1
+		if 0 < len(line) {
+			ff.Append(line)
+			ln := ff.lines[len(ff.lines)-1]
+			ln.srcName, ln.srcLine = name, lineNo
+		}
+	}
+
+	return n, sc.Err()
+}
+
+// ReadFile reads and appends each line of a file, recording the path
+// and 1-based line number on each line as its source. The flat file is
+// considered in sync with disk afterward, so Dirty reports nothing.
+func (ff *FlatFile) ReadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = ff.readFrom(file, path)
+	if err == nil {
+		ff.markClean()
+	}
+
+	return err
+}
+
+// ReadFiles reads every file matching the given glob patterns, in
+// sorted path order with duplicates read once, so a directory of daily
+// drops loads in one call. Each line records its source file and line
+// number. A pattern matching no files is an error, catching feeds that
+// silently failed to arrive.
+func (ff *FlatFile) ReadFiles(patterns ...string) error {
+	var (
+		paths []string
+		seen  = make(map[string]bool)
+	)
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+
+		if len(matches) == 0 {
+			return fmt.Errorf("flatfile: no files match %q", pattern)
+		}
+
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := ff.ReadFile(path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteTo writes each line to a writer, terminated with newlines. It
+// returns the number of bytes written.
+func (ff *FlatFile) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for i, ln := range ff.lines {
+		s, err := ff.enforceWidth(ln.String(), i)
+		if err != nil {
+			return n, err
+		}
+
+		m, err := io.WriteString(w, s+"\n")
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	tln, err := ff.trailerLine()
+	if err != nil {
+		return n, err
+	}
+
+	if tln != nil {
+		s, err := ff.enforceWidth(tln.String(), -1)
+		if err != nil {
+			return n, err
+		}
+
+		m, err := io.WriteString(w, s+"\n")
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	ff.markClean()
+	return n, nil
+}
+
+// WriteFile writes a flat file to a file.
+func (ff *FlatFile) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = ff.WriteTo(file)
+	return err
+}
+
+// String returns a flat file rendered with each line terminated by a
+// newline.
+func (ff *FlatFile) String() string {
+	var sb strings.Builder
+	sb.Grow(ff.ByteLen())
+	for _, ln := range ff.lines {
+		sb.WriteString(ln.String())
+		sb.WriteByte('\n')
+	}
+
+	if tln, err := ff.trailerLine(); err == nil && tln != nil {
+		sb.WriteString(tln.String())
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// Bytes returns a flat file rendered with each line terminated by a
+// newline.
+func (ff *FlatFile) Bytes() []byte {
+	return []byte(ff.String())
+}
+
+// ByteLen returns the length of a flat file's string representation.
+func (ff *FlatFile) ByteLen() int {
+	var n int
+	for _, ln := range ff.lines {
+		n += ln.ByteLen() + 1
+	}
+
+	return n
+}
+
+// MarshalJSON returns a flat file as a JSON array of lines. A flat file
+// holding metadata is instead wrapped as an object holding the metadata
+// and lines.
+func (ff *FlatFile) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	// The JSON form carries each field's layout alongside its value, so
+	// it runs a few times the rendered width.
+	buf.Grow(4 * ff.ByteLen())
+	if 0 < len(ff.meta) {
+		meta, err := json.Marshal(ff.meta)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString(`{"meta":`)
+		buf.Write(meta)
+		buf.WriteString(`,"lines":`)
+	}
+
+	buf.WriteByte('[')
+	for i, ln := range ff.lines {
+		if 0 < i {
+			buf.WriteByte(',')
+		}
+
+		if err := ln.appendJSON(&buf); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte(']')
+	if 0 < len(ff.meta) {
+		buf.WriteByte('}')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON parses a flat file from a JSON array of lines, or from
+// the wrapped object form MarshalJSON emits for flat files holding
+// metadata, replacing any existing lines and metadata.
+func (ff *FlatFile) UnmarshalJSON(b []byte) error {
+	var lns []*Line
+	ff.meta = nil
+	if err := json.Unmarshal(b, &lns); err != nil {
+		var aux struct {
+			Meta  map[string]string `json:"meta"`
+			Lines []*Line           `json:"lines"`
+		}
+
+		if err := json.Unmarshal(b, &aux); err != nil {
+			return err
+		}
+
+		lns = aux.Lines
+		ff.meta = aux.Meta
+	}
+
+	ff.lines = lns
+	ff.shared = false
+	return nil
+}
@@ -0,0 +1,3343 @@
+package flatfile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// testFmt describes the layout used throughout the tests: a first name,
+// a last name, and a three-digit account balance.
+var testFmt = LineFmt{
+	NewFormat("first", 0, 8, Str),
+	NewFormat("last", 8, 8, Str),
+	NewFormat("balance", 16, 3, Num),
+}
+
+// testFmtr ignores the raw line and always returns testFmt.
+func testFmtr(line string) LineFmt {
+	return testFmt
+}
+
+// testLines is a small, well-formed flat file body under testFmt.
+var testLines = []string{
+	"alice   anderson100",
+	"bob     baker   25 ",
+	"carol   clark   75 ",
+}
+
+// newTestFile returns a flat file loaded with testLines.
+func newTestFile() *FlatFile {
+	ff := New(testFmtr)
+	ff.Append(testLines...)
+	return ff
+}
+
+// equalFiles reports whether two flat files render to the same string.
+func equalFiles(ff0, ff1 *FlatFile) bool {
+	return ff0.String() == ff1.String()
+}
+
+func TestGetSet(t *testing.T) {
+	ff := newTestFile()
+	if v, err := ff.Get(0, "first"); err != nil || v != "alice" {
+		t.Fatalf("Get(0, first): got %q, %v", v, err)
+	}
+
+	if v, err := ff.Get(1, "balance"); err != nil || v != "25" {
+		t.Fatalf("Get(1, balance): got %q, %v", v, err)
+	}
+
+	if err := ff.Set(0, "balance", "999"); err != nil {
+		t.Fatalf("Set(0, balance): %v", err)
+	}
+
+	if v, _ := ff.Get(0, "balance"); v != "999" {
+		t.Fatalf("Get(0, balance): got %q, want 999", v)
+	}
+
+	if err := ff.Set(0, "balance", "1000"); err == nil {
+		t.Fatal("Set(0, balance, 1000): expected length error")
+	}
+
+	if _, err := ff.Get(0, "missing"); err == nil {
+		t.Fatal("Get(0, missing): expected key error")
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	ff := newTestFile()
+	for i, line := range testLines {
+		if s := ff.lines[i].String(); s != line {
+			t.Fatalf("line %d: got %q, want %q", i, s, line)
+		}
+	}
+
+	ff1 := New(testFmtr)
+	if _, err := ff1.ReadFrom(strings.NewReader(ff.String())); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+
+	if !equalFiles(ff, ff1) {
+		t.Fatalf("round trip: got %q, want %q", ff1.String(), ff.String())
+	}
+}
+
+func TestMarshalJSONRoundTrip(t *testing.T) {
+	ff := newTestFile()
+	b, err := ff.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+
+	var ff1 FlatFile
+	if err := ff1.UnmarshalJSON(b); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+
+	for i := range testLines {
+		for _, key := range []string{"first", "last", "balance"} {
+			v0, _ := ff.Get(i, key)
+			v1, _ := ff1.Get(i, key)
+			if v0 != v1 {
+				t.Fatalf("line %d, key %q: got %q, want %q", i, key, v1, v0)
+			}
+		}
+	}
+
+	if !equalFiles(ff, &ff1) {
+		t.Fatalf("round trip: got %q, want %q", ff1.String(), ff.String())
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ff := newTestFile()
+	snap := ff.Snapshot()
+
+	if err := ff.Set(0, "first", "alfred"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	ff.Append("dave    dunn    50 ")
+	if v, _ := snap.Get(0, "first"); v != "alice" {
+		t.Fatalf("snapshot Get(0, first): got %q, want alice", v)
+	}
+
+	if n := snap.Len(); n != len(testLines) {
+		t.Fatalf("snapshot Len: got %d, want %d", n, len(testLines))
+	}
+
+	// Mutating the snapshot must not leak back into the original.
+	if err := snap.Set(1, "last", "burton"); err != nil {
+		t.Fatalf("snapshot Set: %v", err)
+	}
+
+	if v, _ := ff.Get(1, "last"); v != "baker" {
+		t.Fatalf("Get(1, last): got %q, want baker", v)
+	}
+}
+
+func TestLinesIterator(t *testing.T) {
+	ff := newTestFile()
+
+	var n int
+	ff.Lines()(func(i int, ln *Line) bool {
+		if s := ln.String(); s != testLines[i] {
+			t.Fatalf("line %d: got %q, want %q", i, s, testLines[i])
+		}
+
+		n++
+		return true
+	})
+
+	if n != len(testLines) {
+		t.Fatalf("yielded %d lines, want %d", n, len(testLines))
+	}
+
+	// Breaking early stops iteration.
+	n = 0
+	ff.Lines()(func(i int, ln *Line) bool {
+		n++
+		return false
+	})
+
+	if n != 1 {
+		t.Fatalf("yielded %d lines after break, want 1", n)
+	}
+
+	var keys []string
+	ff.Line(0).Fields()(func(i int, f Field) bool {
+		keys = append(keys, f.Key())
+		return true
+	})
+
+	if strings.Join(keys, ",") != "first,last,balance" {
+		t.Fatalf("field keys: got %q", strings.Join(keys, ","))
+	}
+}
+
+func TestMapFilter(t *testing.T) {
+	ff := newTestFile()
+
+	upper := ff.Map(func(ln *Line) *Line {
+		v, _ := ln.Get("first")
+		_ = ln.Set("first", strings.ToUpper(v))
+		return ln
+	})
+
+	if v, _ := upper.Get(0, "first"); v != "ALICE" {
+		t.Fatalf("mapped Get(0, first): got %q, want ALICE", v)
+	}
+
+	if v, _ := ff.Get(0, "first"); v != "alice" {
+		t.Fatalf("original Get(0, first): got %q, want alice", v)
+	}
+
+	rich := ff.Filter(func(ln *Line) bool {
+		v, _ := ln.Get("balance")
+		return v == "100"
+	})
+
+	if rich.Len() != 1 {
+		t.Fatalf("filtered Len: got %d, want 1", rich.Len())
+	}
+}
+
+func TestWhere(t *testing.T) {
+	ff := newTestFile()
+	view := ff.Where(func(ln *Line) bool {
+		v, _ := ln.Get("balance")
+		return v != "100"
+	})
+
+	if view.Len() != 2 {
+		t.Fatalf("view Len: got %d, want 2", view.Len())
+	}
+
+	if v, _ := view.Get(0, "first"); v != "bob" {
+		t.Fatalf("view Get(0, first): got %q, want bob", v)
+	}
+
+	// Mutating the original must not disturb the view.
+	if err := ff.Set(1, "first", "robert"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if v, _ := view.Get(0, "first"); v != "bob" {
+		t.Fatalf("view Get(0, first) after Set: got %q, want bob", v)
+	}
+}
+
+func TestHeadTailSlice(t *testing.T) {
+	ff := newTestFile()
+
+	if got := ff.Head(2).Len(); got != 2 {
+		t.Fatalf("Head(2).Len: got %d, want 2", got)
+	}
+
+	if got := ff.Head(10).Len(); got != 3 {
+		t.Fatalf("Head(10).Len: got %d, want 3", got)
+	}
+
+	tail := ff.Tail(1)
+	if v, _ := tail.Get(0, "first"); v != "carol" {
+		t.Fatalf("Tail(1) Get(0, first): got %q, want carol", v)
+	}
+
+	mid := ff.Slice(1, 2)
+	if v, _ := mid.Get(0, "first"); mid.Len() != 1 || v != "bob" {
+		t.Fatalf("Slice(1, 2): got len %d, first %q", mid.Len(), v)
+	}
+}
+
+func TestInsertAtSplice(t *testing.T) {
+	ff := newTestFile()
+	ff.InsertAt(1, NewLine(testFmt, "dave    dunn    50 "))
+
+	if v, _ := ff.Get(1, "first"); ff.Len() != 4 || v != "dave" {
+		t.Fatalf("InsertAt: got len %d, line 1 first %q", ff.Len(), v)
+	}
+
+	ff.Splice(1, 3, NewLine(testFmt, "erin    evans   10 "))
+	if v, _ := ff.Get(1, "first"); ff.Len() != 3 || v != "erin" {
+		t.Fatalf("Splice: got len %d, line 1 first %q", ff.Len(), v)
+	}
+
+	if v, _ := ff.Get(2, "first"); v != "carol" {
+		t.Fatalf("Splice: line 2 first got %q, want carol", v)
+	}
+}
+
+func TestSwapMove(t *testing.T) {
+	ff := newTestFile()
+	ff.Swap(0, 2)
+
+	if v, _ := ff.Get(0, "first"); v != "carol" {
+		t.Fatalf("Swap: line 0 first got %q, want carol", v)
+	}
+
+	ff.Move(2, 0)
+	if v, _ := ff.Get(0, "first"); v != "alice" {
+		t.Fatalf("Move: line 0 first got %q, want alice", v)
+	}
+
+	if v, _ := ff.Get(1, "first"); v != "carol" {
+		t.Fatalf("Move: line 1 first got %q, want carol", v)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	ff := newTestFile()
+	other := New(testFmtr)
+	other.Append("dave    dunn    50 ")
+
+	if err := ff.Concat(other); err != nil {
+		t.Fatalf("Concat: %v", err)
+	}
+
+	if v, _ := ff.Get(3, "first"); ff.Len() != 4 || v != "dave" {
+		t.Fatalf("Concat: got len %d, line 3 first %q", ff.Len(), v)
+	}
+
+	short := New(func(string) LineFmt { return LineFmt{NewFormat("only", 0, 5, Str)} })
+	short.Append("tiny ")
+	if err := ff.Concat(short); err == nil {
+		t.Fatal("Concat: expected error for short line")
+	}
+
+	if ff.Len() != 4 {
+		t.Fatalf("Concat: receiver modified on error, len %d", ff.Len())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	ff0, ff1 := newTestFile(), newTestFile()
+	if !ff0.Equal(ff1) {
+		t.Fatal("Equal: identical files compare unequal")
+	}
+
+	_ = ff1.Set(2, "balance", "76")
+	if ff0.Equal(ff1) {
+		t.Fatal("Equal: differing files compare equal")
+	}
+
+	ff1 = newTestFile()
+	ff1.Remove(2)
+	if ff0.Equal(ff1) {
+		t.Fatal("Equal: files of differing length compare equal")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ff0, ff1 := newTestFile(), newTestFile()
+	if rep := ff0.Diff(ff1, "first"); !rep.Empty() {
+		t.Fatalf("Diff: identical files produced %q", rep.String())
+	}
+
+	ff1.Remove(1)
+	ff1.Append("dave    dunn    50 ")
+	_ = ff1.Set(0, "balance", "999")
+
+	rep := ff0.Diff(ff1, "first")
+	if len(rep.Added) != 1 || len(rep.Removed) != 1 || len(rep.Changed) != 1 {
+		t.Fatalf("Diff: got %d added, %d removed, %d changed", len(rep.Added), len(rep.Removed), len(rep.Changed))
+	}
+
+	if v, _ := rep.Added[0].Get("first"); v != "dave" {
+		t.Fatalf("Diff added: got %q, want dave", v)
+	}
+
+	if v, _ := rep.Removed[0].Get("first"); v != "bob" {
+		t.Fatalf("Diff removed: got %q, want bob", v)
+	}
+
+	c := rep.Changed[0]
+	if c.Key[0] != "alice" || len(c.Changes) != 1 || c.Changes[0] != (FieldChange{Key: "balance", Old: "100", New: "999"}) {
+		t.Fatalf("Diff changed: got %+v", c)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ff0, ff1 := newTestFile(), newTestFile()
+	ff1.Remove(1)
+	ff1.Append("dave    dunn    50 ")
+	_ = ff1.Set(0, "balance", "999")
+
+	if err := ff0.Apply(ff0.Diff(ff1, "first")); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if !ff0.Equal(ff1) {
+		t.Fatalf("Apply: got %q, want %q", ff0.String(), ff1.String())
+	}
+
+	bad := DiffReport{
+		KeyFields: []string{"first"},
+		Changed:   []LineDiff{{Key: []string{"zed"}, Changes: []FieldChange{{Key: "balance", New: "0"}}}},
+	}
+
+	if err := ff0.Apply(bad); err == nil {
+		t.Fatal("Apply: expected error for missing record")
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	ff := newTestFile()
+	ff.Append("alice   avery   1  ")
+
+	ff.Dedupe("first")
+	if v, _ := ff.Get(0, "last"); ff.Len() != 3 || v != "anderson" {
+		t.Fatalf("Dedupe: got len %d, line 0 last %q", ff.Len(), v)
+	}
+
+	ff = newTestFile()
+	ff.Append("alice   avery   1  ")
+	ff.DedupeLast("first")
+	if v, _ := ff.Get(2, "last"); ff.Len() != 3 || v != "avery" {
+		t.Fatalf("DedupeLast: got len %d, last line %q", ff.Len(), v)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	ff := newTestFile()
+	ff.Append("alice   avery   1  ")
+
+	groups := ff.GroupBy("first")
+	if len(groups) != 3 {
+		t.Fatalf("GroupBy: got %d groups, want 3", len(groups))
+	}
+
+	if g := groups["alice"]; g.Len() != 2 {
+		t.Fatalf("GroupBy: alice group has %d lines, want 2", g.Len())
+	}
+
+	if v, _ := groups["bob"].Get(0, "last"); v != "baker" {
+		t.Fatalf("GroupBy: bob group last got %q, want baker", v)
+	}
+}
+
+func TestAggregations(t *testing.T) {
+	ff := newTestFile()
+
+	if sum, err := ff.Sum("balance"); err != nil || sum != 200 {
+		t.Fatalf("Sum: got %v, %v", sum, err)
+	}
+
+	if min, err := ff.Min("balance"); err != nil || min != 25 {
+		t.Fatalf("Min: got %v, %v", min, err)
+	}
+
+	if max, err := ff.Max("balance"); err != nil || max != 100 {
+		t.Fatalf("Max: got %v, %v", max, err)
+	}
+
+	if avg, err := ff.Avg("balance"); err != nil || avg != 200.0/3 {
+		t.Fatalf("Avg: got %v, %v", avg, err)
+	}
+
+	if n := ff.Count("balance"); n != 3 {
+		t.Fatalf("Count: got %d, want 3", n)
+	}
+
+	if _, err := ff.Sum("first"); err == nil {
+		t.Fatal("Sum: expected error for non-numeric field")
+	}
+}
+
+func TestColumn(t *testing.T) {
+	ff := newTestFile()
+	firsts, err := ff.Column("first")
+	if err != nil {
+		t.Fatalf("Column: %v", err)
+	}
+
+	if strings.Join(firsts, ",") != "alice,bob,carol" {
+		t.Fatalf("Column: got %q", strings.Join(firsts, ","))
+	}
+
+	balances, err := ff.NumericColumn("balance")
+	if err != nil || len(balances) != 3 || balances[0] != 100 {
+		t.Fatalf("NumericColumn: got %v, %v", balances, err)
+	}
+
+	if _, err := ff.Column("missing"); err == nil {
+		t.Fatal("Column: expected error for missing key")
+	}
+}
+
+func TestBuildIndex(t *testing.T) {
+	ff := newTestFile()
+	ff.Append("alice   avery   1  ")
+
+	if err := ff.BuildIndex("first"); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+
+	is, err := ff.LookupIndexed("first", "alice")
+	if err != nil || len(is) != 2 || is[0] != 0 || is[1] != 3 {
+		t.Fatalf("LookupIndexed: got %v, %v", is, err)
+	}
+
+	if is, _ := ff.LookupIndexed("first", "zed"); len(is) != 0 {
+		t.Fatalf("LookupIndexed: got %v for absent value", is)
+	}
+
+	// Mutation drops the index.
+	ff.Remove(0)
+	if _, err := ff.LookupIndexed("first", "alice"); err == nil {
+		t.Fatal("LookupIndexed: expected error after mutation")
+	}
+}
+
+func TestLinesWhere(t *testing.T) {
+	ff := newTestFile()
+	ff.Append("alice   avery   1  ")
+
+	// Linear scan without an index.
+	lns := ff.LinesWhere("first", "alice")
+	if len(lns) != 2 {
+		t.Fatalf("LinesWhere: got %d lines, want 2", len(lns))
+	}
+
+	// Indexed lookup returns the same lines.
+	if err := ff.BuildIndex("first"); err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+
+	indexed := ff.LinesWhere("first", "alice")
+	if len(indexed) != 2 || !indexed[0].Equal(lns[0]) || !indexed[1].Equal(lns[1]) {
+		t.Fatalf("LinesWhere: indexed lookup disagrees with scan")
+	}
+}
+
+func TestSortByKeys(t *testing.T) {
+	ff := newTestFile()
+	ff.SortByKeys("balance")
+
+	balances, _ := ff.Column("balance")
+	if strings.Join(balances, ",") != "25,75,100" {
+		t.Fatalf("SortByKeys(balance): got %q", strings.Join(balances, ","))
+	}
+
+	less := func(ln0, ln1 *Line) bool { return compareByKeys(ln0, ln1, []string{"balance"}, nil) < 0 }
+	if !ff.IsSorted(less) {
+		t.Fatal("IsSorted: sorted file reported unsorted")
+	}
+
+	ff.Reverse()
+	if ff.IsSorted(less) {
+		t.Fatal("IsSorted: reversed file reported sorted")
+	}
+}
+
+// foldCollator compares strings case-insensitively, standing in for a
+// language collator in tests.
+type foldCollator struct{}
+
+func (foldCollator) CompareString(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func TestSortByKeysCollated(t *testing.T) {
+	ff := New(testFmtr)
+	ff.Append(
+		"Bob     baker   25 ",
+		"alice   anderson100",
+	)
+
+	ff.SortByKeysCollated(foldCollator{}, "first")
+	firsts, _ := ff.Column("first")
+	if strings.Join(firsts, ",") != "alice,Bob" {
+		t.Fatalf("SortByKeysCollated: got %q", strings.Join(firsts, ","))
+	}
+}
+
+func TestSample(t *testing.T) {
+	ff := newTestFile()
+
+	s := ff.Sample(2, 1)
+	if s.Len() != 2 {
+		t.Fatalf("Sample(2): got %d lines", s.Len())
+	}
+
+	if !s.Equal(ff.Sample(2, 1)) {
+		t.Fatal("Sample: same seed produced differing samples")
+	}
+
+	if got := ff.Sample(10, 1); !got.Equal(ff) {
+		t.Fatal("Sample: oversized sample should copy every line")
+	}
+}
+
+func TestProfile(t *testing.T) {
+	ff := newTestFile()
+	ff.Append("dave                ")
+
+	profiles := ff.Profile()
+	if len(profiles) != 3 {
+		t.Fatalf("Profile: got %d fields, want 3", len(profiles))
+	}
+
+	byKey := make(map[string]FieldProfile, len(profiles))
+	for _, p := range profiles {
+		byKey[p.Key] = p
+	}
+
+	first := byKey["first"]
+	if first.Filled != 4 || first.FillRate() != 1 || first.MinLen != 3 || first.MaxLen != 5 || first.Distinct != 4 {
+		t.Fatalf("Profile first: got %+v", first)
+	}
+
+	balance := byKey["balance"]
+	if balance.Filled != 3 || !balance.Numeric || balance.NumericMin != 25 || balance.NumericMax != 100 {
+		t.Fatalf("Profile balance: got %+v", balance)
+	}
+
+	if byKey["last"].Numeric {
+		t.Fatal("Profile last: reported numeric")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	raw := strings.Join([]string{
+		"alice   anderson100",
+		"short",
+		"bob     baker   25 ",
+	}, "\n")
+
+	anom, err := Analyze(strings.NewReader(raw), testFmtr)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	if anom.LineLengths[19] != 2 || anom.LineLengths[5] != 1 {
+		t.Fatalf("Analyze lengths: got %v", anom.LineLengths)
+	}
+
+	if len(anom.Rejected) != 1 || anom.Rejected[0] != 2 {
+		t.Fatalf("Analyze rejected: got %v", anom.Rejected)
+	}
+
+	if len(anom.BlankFields) != 0 {
+		t.Fatalf("Analyze blank fields: got %v", anom.BlankFields)
+	}
+}
+
+func TestReformat(t *testing.T) {
+	ff := newTestFile()
+	target := []Format{
+		NewFormat("balance", 0, 5, Num),
+		NewFormat("name", 5, 10, Str),
+	}
+
+	out, err := ff.Reformat(target, map[string]string{"name": "first"})
+	if err != nil {
+		t.Fatalf("Reformat: %v", err)
+	}
+
+	if s := out.Line(0).String(); s != "100  alice     " {
+		t.Fatalf("Reformat: line 0 got %q", s)
+	}
+
+	// Overflow errors unless truncation is requested.
+	narrow := []Format{NewFormat("last", 0, 4, Str)}
+	if _, err := ff.Reformat(narrow, nil); err == nil {
+		t.Fatal("Reformat: expected overflow error")
+	}
+
+	out, err = ff.ReformatTruncate(narrow, nil)
+	if err != nil {
+		t.Fatalf("ReformatTruncate: %v", err)
+	}
+
+	if v, _ := out.Get(0, "last"); v != "ande" {
+		t.Fatalf("ReformatTruncate: got %q, want ande", v)
+	}
+}
+
+func TestRenameKey(t *testing.T) {
+	ff := newTestFile()
+	if err := ff.RenameKey("first", "given"); err != nil {
+		t.Fatalf("RenameKey: %v", err)
+	}
+
+	if v, _ := ff.Get(0, "given"); v != "alice" {
+		t.Fatalf("Get(0, given): got %q, want alice", v)
+	}
+
+	if _, err := ff.Get(0, "first"); err == nil {
+		t.Fatal("Get(0, first): expected error after rename")
+	}
+
+	if err := ff.RenameKey("given", "last"); err == nil {
+		t.Fatal("RenameKey: expected error for existing key")
+	}
+
+	if err := ff.RenameKey("missing", "x"); err == nil {
+		t.Fatal("RenameKey: expected error for missing key")
+	}
+}
+
+func TestAddField(t *testing.T) {
+	ff := newTestFile()
+	err := ff.AddField("initials", 19, 2, func(ln *Line) string {
+		first, _ := ln.Get("first")
+		last, _ := ln.Get("last")
+		return first[:1] + last[:1]
+	})
+
+	if err != nil {
+		t.Fatalf("AddField: %v", err)
+	}
+
+	if v, _ := ff.Get(0, "initials"); v != "aa" {
+		t.Fatalf("Get(0, initials): got %q, want aa", v)
+	}
+
+	if s := ff.Line(1).String(); s != "bob     baker   25 bb" {
+		t.Fatalf("line 1: got %q", s)
+	}
+
+	if err := ff.AddField("initials", 21, 2, func(*Line) string { return "" }); err == nil {
+		t.Fatal("AddField: expected error for existing key")
+	}
+}
+
+func TestSplitMergeFields(t *testing.T) {
+	ff := newTestFile()
+	if err := ff.SplitField("last", []string{"l0", "l1"}, []int{4, 4}); err != nil {
+		t.Fatalf("SplitField: %v", err)
+	}
+
+	if v, _ := ff.Get(0, "l0"); v != "ande" {
+		t.Fatalf("Get(0, l0): got %q, want ande", v)
+	}
+
+	if v, _ := ff.Get(0, "l1"); v != "rson" {
+		t.Fatalf("Get(0, l1): got %q, want rson", v)
+	}
+
+	if s := ff.Line(0).String(); s != testLines[0] {
+		t.Fatalf("SplitField rendering: got %q, want %q", s, testLines[0])
+	}
+
+	if err := ff.MergeFields("last", "l0", "l1"); err != nil {
+		t.Fatalf("MergeFields: %v", err)
+	}
+
+	if v, _ := ff.Get(0, "last"); v != "anderson" {
+		t.Fatalf("Get(0, last): got %q, want anderson", v)
+	}
+
+	if err := ff.MergeFields("x", "first", "balance"); err == nil {
+		t.Fatal("MergeFields: expected error for non-adjacent fields")
+	}
+}
+
+func TestReorderFields(t *testing.T) {
+	ff := newTestFile()
+	if err := ff.ReorderFields("balance", "first", "last"); err != nil {
+		t.Fatalf("ReorderFields: %v", err)
+	}
+
+	if s := ff.Line(0).String(); s != "100alice   anderson" {
+		t.Fatalf("ReorderFields: line 0 got %q", s)
+	}
+
+	if err := ff.ReorderFields("first", "first", "last"); err == nil {
+		t.Fatal("ReorderFields: expected error for repeated key")
+	}
+}
+
+func TestSetRawSetStr(t *testing.T) {
+	ff := newTestFile()
+	if err := ff.SetStr(0, "ada     adams   5  "); err != nil {
+		t.Fatalf("SetStr: %v", err)
+	}
+
+	if v, _ := ff.Get(0, "first"); v != "ada" {
+		t.Fatalf("Get(0, first): got %q, want ada", v)
+	}
+
+	if err := ff.SetStr(0, "short"); err == nil {
+		t.Fatal("SetStr: expected error for short text")
+	}
+
+	ln := ff.Line(1)
+	if err := ln.SetRaw("eve     edwards 30 "); err != nil {
+		t.Fatalf("SetRaw: %v", err)
+	}
+
+	if v, _ := ln.Get("last"); v != "edwards" {
+		t.Fatalf("Get(last): got %q, want edwards", v)
+	}
+
+	if err := ln.SetRaw("short"); err != nil {
+		if v, _ := ln.Get("last"); v != "edwards" {
+			t.Fatalf("SetRaw: line modified on error, last %q", v)
+		}
+	} else {
+		t.Fatal("SetRaw: expected error for short text")
+	}
+}
+
+func TestBuildLine(t *testing.T) {
+	ln, err := BuildLine(testFmt, map[string]string{"first": "dave", "balance": "50"})
+	if err != nil {
+		t.Fatalf("BuildLine: %v", err)
+	}
+
+	if s := ln.String(); s != "dave            50 " {
+		t.Fatalf("BuildLine: got %q", s)
+	}
+
+	if _, err := BuildLine(testFmt, map[string]string{"first": "maximilian"}); err == nil {
+		t.Fatal("BuildLine: expected overflow error")
+	}
+
+	if _, err := BuildLine(testFmt, map[string]string{"missing": "x"}); err == nil {
+		t.Fatal("BuildLine: expected error for unknown value key")
+	}
+}
+
+func TestNewLineFromMap(t *testing.T) {
+	ff := newTestFile()
+	ln, err := NewLineFromMap(testFmt, ff.KeyValues(0))
+	if err != nil {
+		t.Fatalf("NewLineFromMap: %v", err)
+	}
+
+	if !ln.Equal(ff.Line(0)) {
+		t.Fatalf("NewLineFromMap: round trip got %q, want %q", ln.String(), ff.Line(0).String())
+	}
+
+	// Unknown keys are ignored.
+	if _, err := NewLineFromMap(testFmt, map[string]string{"first": "zed", "extra": "x"}); err != nil {
+		t.Fatalf("NewLineFromMap: %v", err)
+	}
+}
+
+func TestLineMerge(t *testing.T) {
+	ln := NewLine(testFmt, testLines[0])
+	patch, _ := BuildLine(testFmt, map[string]string{"balance": "42"})
+
+	if err := ln.Merge(patch, false); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	// Without overwrite, the existing balance wins.
+	if v, _ := ln.Get("balance"); v != "100" {
+		t.Fatalf("Merge: balance got %q, want 100", v)
+	}
+
+	if err := ln.Merge(patch, true); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	if v, _ := ln.Get("balance"); v != "42" {
+		t.Fatalf("Merge overwrite: balance got %q, want 42", v)
+	}
+
+	if v, _ := ln.Get("first"); v != "alice" {
+		t.Fatalf("Merge: first got %q, want alice", v)
+	}
+}
+
+func TestSetValues(t *testing.T) {
+	ff := newTestFile()
+	if err := ff.SetValues(0, map[string]string{"first": "ada", "balance": "7"}); err != nil {
+		t.Fatalf("SetValues: %v", err)
+	}
+
+	if v, _ := ff.Get(0, "balance"); v != "7" {
+		t.Fatalf("Get(0, balance): got %q, want 7", v)
+	}
+
+	// A bad key leaves every field untouched.
+	err := ff.SetValues(0, map[string]string{"first": "eve", "missing": "x"})
+	if err == nil {
+		t.Fatal("SetValues: expected error for missing key")
+	}
+
+	if v, _ := ff.Get(0, "first"); v != "ada" {
+		t.Fatalf("SetValues: first modified on error, got %q", v)
+	}
+}
+
+func TestParseRenderHooks(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("name", 0, 8, Str).WithParse(func(raw string) string {
+			return strings.ToUpper(strings.TrimSpace(raw))
+		}),
+		NewFormat("phone", 8, 8, Str).WithParse(func(raw string) string {
+			return strings.ReplaceAll(strings.TrimSpace(raw), "-", "")
+		}).WithRender(func(v string) string {
+			if len(v) == 7 {
+				return v[:3] + "-" + v[3:]
+			}
+
+			return v
+		}),
+	}
+
+	ln := NewLine(lf, "alice   555-0142")
+	if v, _ := ln.Get("name"); v != "ALICE" {
+		t.Fatalf("parse hook: name got %q, want ALICE", v)
+	}
+
+	if v, _ := ln.Get("phone"); v != "5550142" {
+		t.Fatalf("parse hook: phone got %q, want 5550142", v)
+	}
+
+	if s := ln.String(); s != "ALICE   555-0142" {
+		t.Fatalf("render hook: got %q", s)
+	}
+}
+
+func TestZeroPadded(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("id", 0, 8, Num).ZeroPadded(),
+		NewFormat("name", 8, 5, Str),
+	}
+
+	ln := NewLine(lf, "00000042alice")
+	if v, _ := ln.Get("id"); v != "42" {
+		t.Fatalf("parse: id got %q, want 42", v)
+	}
+
+	if s := ln.String(); s != "00000042alice" {
+		t.Fatalf("render: got %q", s)
+	}
+
+	_ = ln.Set("id", "")
+	if s := ln.String(); s != "00000000alice" {
+		t.Fatalf("render blank: got %q", s)
+	}
+}
+
+func TestNumberLocale(t *testing.T) {
+	lf := LineFmt{NewFormat("amount", 0, 10, Num).WithNumberLocale(NumberEU)}
+
+	ln := NewLine(lf, "  1.234,56")
+	if v, _ := ln.Get("amount"); v != "1234.56" {
+		t.Fatalf("parse: got %q, want 1234.56", v)
+	}
+
+	if s := ln.String(); s != "1234,56   " {
+		t.Fatalf("render: got %q", s)
+	}
+}
+
+func TestSignedFields(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("debit", 0, 6, Num).WithSign(SignLeading).ZeroPadded(),
+		NewFormat("credit", 6, 6, Num).WithSign(SignTrailing).ZeroPadded(),
+	}
+
+	ln := NewLine(lf, "-0012300456-")
+	if v, _ := ln.Get("debit"); v != "-123" {
+		t.Fatalf("parse leading: got %q, want -123", v)
+	}
+
+	if v, _ := ln.Get("credit"); v != "-456" {
+		t.Fatalf("parse trailing: got %q, want -456", v)
+	}
+
+	if s := ln.String(); s != "-0012300456-" {
+		t.Fatalf("render: got %q", s)
+	}
+
+	_ = ln.Set("debit", "7")
+	if s := ln.String(); s != " 0000700456-" {
+		t.Fatalf("render positive: got %q", s)
+	}
+}
+
+func TestEnumFields(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("status", 0, 1, Str).WithEnum(map[string]string{"A": "active", "C": "closed"}),
+		NewFormat("name", 1, 5, Str),
+	}
+
+	ln := NewLine(lf, "Aalice")
+	if label, err := ln.Label("status"); err != nil || label != "active" {
+		t.Fatalf("Label: got %q, %v", label, err)
+	}
+
+	if err := ln.Set("status", "X"); err == nil {
+		t.Fatal("Set: expected error for disallowed code")
+	}
+
+	if err := ln.Set("status", "C"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Fields without an enum return their value.
+	if label, _ := ln.Label("name"); label != "alice" {
+		t.Fatalf("Label(name): got %q", label)
+	}
+}
+
+func TestDefaultValues(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("country", 0, 2, Str).WithDefault("US", true),
+		NewFormat("region", 2, 2, Str).WithDefault("NA", false),
+	}
+
+	ln := NewLine(lf, "    ")
+	if v, _ := ln.Get("country"); v != "US" {
+		t.Fatalf("Get(country): got %q, want US", v)
+	}
+
+	if v, _ := ln.Get("region"); v != "NA" {
+		t.Fatalf("Get(region): got %q, want NA", v)
+	}
+
+	// country emits its default, region stays blank on write.
+	if s := ln.String(); s != "US  " {
+		t.Fatalf("String: got %q", s)
+	}
+
+	ln = NewLine(lf, "DEEU")
+	if s := ln.String(); s != "DEEU" {
+		t.Fatalf("String: got %q, want DEEU", s)
+	}
+}
+
+func TestSequenceFields(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("seq", 0, 4, Num).Sequence(1),
+		NewFormat("name", 4, 5, Str),
+	}
+
+	ff := New(func(string) LineFmt { return lf })
+	ff.Append("    alice", "    bob  ")
+
+	if s := ff.Line(0).String(); s != "0001alice" {
+		t.Fatalf("line 0: got %q", s)
+	}
+
+	if s := ff.Line(1).String(); s != "0002bob  " {
+		t.Fatalf("line 1: got %q", s)
+	}
+
+	ff.Reverse()
+	ff.Resequence()
+	if s := ff.Line(0).String(); s != "0001bob  " {
+		t.Fatalf("resequenced line 0: got %q", s)
+	}
+}
+
+func TestChecksumFields(t *testing.T) {
+	sumDigits := func(values []string) string {
+		var sum int
+		for _, v := range values {
+			for _, r := range v {
+				if '0' <= r && r <= '9' {
+					sum += int(r - '0')
+				}
+			}
+		}
+
+		return strconv.Itoa(sum % 10)
+	}
+
+	lf := LineFmt{
+		NewFormat("amount", 0, 4, Num),
+		NewFormat("check", 4, 1, Num).WithChecksum([]string{"amount"}, sumDigits),
+	}
+
+	ln := NewLine(lf, "1234 ")
+	if s := ln.String(); s != "12340" {
+		t.Fatalf("render: got %q", s)
+	}
+
+	if err := NewLine(lf, "12340").VerifyChecksums(); err != nil {
+		t.Fatalf("VerifyChecksums: %v", err)
+	}
+
+	if err := NewLine(lf, "12349").VerifyChecksums(); err == nil {
+		t.Fatal("VerifyChecksums: expected mismatch error")
+	}
+}
+
+func TestTrailer(t *testing.T) {
+	trailerFmt := []Format{
+		NewFormat("tag", 0, 1, Str),
+		NewFormat("count", 1, 6, Num).ZeroPadded(),
+		NewFormat("total", 7, 6, Num).ZeroPadded(),
+	}
+
+	ff := newTestFile().WithTrailer(trailerFmt, func(st FileStats) map[string]string {
+		return map[string]string{
+			"tag":   "T",
+			"count": strconv.Itoa(st.Lines),
+			"total": strconv.FormatFloat(st.Sums["balance"], 'f', 0, 64),
+		}
+	})
+
+	want := strings.Join(append(testLines, "T000003000200"), "\n") + "\n"
+	if got := ff.String(); got != want {
+		t.Fatalf("String with trailer:\ngot  %q\nwant %q", got, want)
+	}
+
+	var sb strings.Builder
+	if _, err := ff.WriteTo(&sb); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+
+	if sb.String() != want {
+		t.Fatalf("WriteTo with trailer: got %q", sb.String())
+	}
+}
+
+func TestChecksumAnnotations(t *testing.T) {
+	ff := newTestFile()
+	ff.AnnotateChecksums(CRC32)
+
+	if len(ff.Line(0).Checksum()) != 8 {
+		t.Fatalf("Checksum: got %q", ff.Line(0).Checksum())
+	}
+
+	if bad := ff.VerifyAnnotatedChecksums(); len(bad) != 0 {
+		t.Fatalf("VerifyAnnotatedChecksums: got %v", bad)
+	}
+
+	_ = ff.Set(1, "balance", "26")
+	if bad := ff.VerifyAnnotatedChecksums(); len(bad) != 1 || bad[0] != 1 {
+		t.Fatalf("VerifyAnnotatedChecksums after edit: got %v", bad)
+	}
+
+	ff.AnnotateChecksums(MD5)
+	if len(ff.Line(0).Checksum()) != 32 {
+		t.Fatalf("Checksum MD5: got %q", ff.Line(0).Checksum())
+	}
+}
+
+func TestMask(t *testing.T) {
+	ff := newTestFile()
+	masked, err := ff.Mask([]string{"last"}, func(v string) string {
+		if len(v) <= 2 {
+			return v
+		}
+
+		return strings.Repeat("*", len(v)-2) + v[len(v)-2:]
+	})
+
+	if err != nil {
+		t.Fatalf("Mask: %v", err)
+	}
+
+	if v, _ := masked.Get(0, "last"); v != "******on" {
+		t.Fatalf("Mask: got %q", v)
+	}
+
+	// The original is untouched.
+	if v, _ := ff.Get(0, "last"); v != "anderson" {
+		t.Fatalf("Mask: original modified, got %q", v)
+	}
+}
+
+func TestEncryptDecryptFields(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("id", 0, 4, Str),
+		NewFormat("ssn", 4, 60, Str),
+	}
+
+	ff := New(func(string) LineFmt { return lf })
+	ff.Append("0001123-45-6789" + strings.Repeat(" ", 49))
+
+	key := []byte("0123456789abcdef")
+	if err := ff.EncryptFields(key, "ssn"); err != nil {
+		t.Fatalf("EncryptFields: %v", err)
+	}
+
+	if v, _ := ff.Get(0, "ssn"); v == "123-45-6789" {
+		t.Fatal("EncryptFields: value unchanged")
+	}
+
+	if err := ff.DecryptFields(key, "ssn"); err != nil {
+		t.Fatalf("DecryptFields: %v", err)
+	}
+
+	if v, _ := ff.Get(0, "ssn"); v != "123-45-6789" {
+		t.Fatalf("DecryptFields: got %q", v)
+	}
+
+	// The wrong key fails authentication.
+	_ = ff.EncryptFields(key, "ssn")
+	if err := ff.DecryptFields([]byte("fedcba9876543210"), "ssn"); err == nil {
+		t.Fatal("DecryptFields: expected error under wrong key")
+	}
+}
+
+func TestPseudonymize(t *testing.T) {
+	ff0, ff1 := newTestFile(), newTestFile()
+	key := []byte("secret")
+
+	ff0.Pseudonymize(key, "first")
+	ff1.Pseudonymize(key, "first")
+
+	v0, _ := ff0.Get(0, "first")
+	v1, _ := ff1.Get(0, "first")
+	if v0 != v1 {
+		t.Fatalf("Pseudonymize: tokens differ, %q vs %q", v0, v1)
+	}
+
+	if v0 == "alice" || len(v0) != len("alice") {
+		t.Fatalf("Pseudonymize: got %q", v0)
+	}
+
+	ff1 = newTestFile()
+	ff1.Pseudonymize([]byte("other"), "first")
+	v1, _ = ff1.Get(0, "first")
+	if v0 == v1 {
+		t.Fatal("Pseudonymize: different keys produced the same token")
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	ff := newTestFile()
+	ff.EnableAudit("jsmith")
+
+	if err := ff.Set(0, "balance", "150"); err != nil {
+		t.Fatal(err)
+	}
+
+	ff.Append("dave    dunn    50 ")
+	removed := ff.Remove(1)
+
+	log := ff.AuditLog()
+	if len(log) != 3 {
+		t.Fatalf("expected 3 audit entries, got %d", len(log))
+	}
+
+	set := log[0]
+	if set.Op != OpSet || set.Line != 0 || set.Key != "balance" || set.Old != "100" || set.New != "150" || set.Actor != "jsmith" {
+		t.Fatalf("unexpected set entry %+v", set)
+	}
+
+	if app := log[1]; app.Op != OpAppend || app.Line != 3 || app.New != "dave    dunn    50 " {
+		t.Fatalf("unexpected append entry %+v", app)
+	}
+
+	if rem := log[2]; rem.Op != OpRemove || rem.Line != 1 || rem.Old != removed.String() {
+		t.Fatalf("unexpected remove entry %+v", rem)
+	}
+
+	for _, e := range log {
+		if e.Time.IsZero() {
+			t.Fatalf("entry %+v has no timestamp", e)
+		}
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	ff := newTestFile()
+	ff.EnableAudit("jsmith")
+
+	if err := ff.Set(0, "balance", "150"); err != nil {
+		t.Fatal(err)
+	}
+
+	ff.Append("dave    dunn    50 ")
+	ff.Remove(1)
+
+	want := ff.String()
+	for i := 0; i < 3; i++ {
+		if err := ff.Undo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !equalFiles(ff, newTestFile()) {
+		t.Fatalf("expected original file after undo, got %q", ff.String())
+	}
+
+	if err := ff.Undo(); err == nil {
+		t.Fatal("expected error undoing past the start")
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := ff.Redo(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := ff.String(); got != want {
+		t.Fatalf("expected %q after redo, got %q", want, got)
+	}
+
+	if err := ff.Redo(); err == nil {
+		t.Fatal("expected error redoing past the end")
+	}
+
+	// A fresh tracked change invalidates the redo stack.
+	if err := ff.Undo(); err != nil {
+		t.Fatal(err)
+	}
+
+	ff.Append("erin    evans   10 ")
+	if err := ff.Redo(); err == nil {
+		t.Fatal("expected redo stack to clear after a new change")
+	}
+}
+
+func TestWriteDirty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dirty.txt")
+	ff := newTestFile()
+	if err := ff.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if dirty := ff.Dirty(); len(dirty) != 0 {
+		t.Fatalf("expected no dirty lines after a full write, got %v", dirty)
+	}
+
+	if err := ff.Set(1, "balance", "999"); err != nil {
+		t.Fatal(err)
+	}
+
+	ff.Append("dave    dunn    50 ")
+	if dirty := ff.Dirty(); len(dirty) != 2 || dirty[0] != 1 || dirty[1] != 3 {
+		t.Fatalf("expected dirty lines [1 3], got %v", dirty)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ff.WriteDirty(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reread := New(testFmtr)
+	if err := reread.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalFiles(ff, reread) {
+		t.Fatalf("expected %q after incremental write, got %q", ff.String(), reread.String())
+	}
+
+	if dirty := ff.Dirty(); len(dirty) != 0 {
+		t.Fatalf("expected no dirty lines after WriteDirty, got %v", dirty)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.txt")
+	if err := newTestFile().WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	sff := NewSafe(testFmtr)
+	if err := sff.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan error, 1)
+	stop, err := sff.Watch(path, time.Millisecond, func(err error) { reloaded <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	grown := newTestFile()
+	grown.Append("dave    dunn    50 ")
+	if err := grown.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a reload after the file changed")
+	}
+
+	if n := sff.Len(); n != 4 {
+		t.Fatalf("expected 4 lines after reload, got %d", n)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(newTestFile().Handler())
+	defer srv.Close()
+
+	get := func(path string, into any) int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return resp.StatusCode
+	}
+
+	var page []map[string]string
+	if code := get("/lines", &page); code != http.StatusOK {
+		t.Fatalf("expected 200 listing lines, got %d", code)
+	}
+
+	if len(page) != 3 || page[0]["first"] != "alice" {
+		t.Fatalf("unexpected page %v", page)
+	}
+
+	if code := get("/lines?offset=1&limit=1", &page); code != http.StatusOK {
+		t.Fatal("expected 200 for paged listing")
+	}
+
+	if len(page) != 1 || page[0]["first"] != "bob" {
+		t.Fatalf("unexpected page %v", page)
+	}
+
+	if code := get("/lines?key=last&value=clark", &page); code != http.StatusOK {
+		t.Fatal("expected 200 for filtered listing")
+	}
+
+	if len(page) != 1 || page[0]["first"] != "carol" {
+		t.Fatalf("unexpected filtered page %v", page)
+	}
+
+	var kvs map[string]string
+	if code := get("/lines/2", &kvs); code != http.StatusOK {
+		t.Fatal("expected 200 fetching a line by index")
+	}
+
+	if kvs["last"] != "clark" {
+		t.Fatalf("unexpected line %v", kvs)
+	}
+
+	if code := get("/lines/9", &kvs); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an out-of-range index, got %d", code)
+	}
+
+	if code := get("/lines?offset=no", &page); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad offset, got %d", code)
+	}
+}
+
+// fakeDB holds the canned result set served, and the statements
+// recorded, by the fake database/sql driver registered below.
+var fakeDB struct {
+	cols  []string
+	rows  [][]driver.Value
+	execs []string
+}
+
+func init() {
+	sql.Register("flatfiletest", fakeDriver{})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not implemented") }
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	fakeDB.execs = append(fakeDB.execs, fmt.Sprintf("%s %v", s.query, args))
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{cols: fakeDB.cols, rows: fakeDB.rows}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) <= r.i {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
+
+func TestFromRows(t *testing.T) {
+	fakeDB.cols = []string{"first", "last", "balance", "ignored"}
+	fakeDB.rows = [][]driver.Value{
+		{"alice", "anderson", int64(100), "x"},
+		{"bob", "baker", int64(25), "x"},
+	}
+
+	db, err := sql.Open("flatfiletest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("select first, last, balance, ignored from accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	ff, err := FromRows(rows, testFmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "alice   anderson100\nbob     baker   25 \n"
+	if got := ff.String(); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestToDB(t *testing.T) {
+	db, err := sql.Open("flatfiletest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fakeDB.execs = nil
+	mapping := map[string]string{"first": "first_name", "last": "last_name"}
+	if err := newTestFile().ToDB(context.Background(), db, "accounts", mapping); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fakeDB.execs) != 1 {
+		t.Fatalf("expected 1 batched insert, got %d", len(fakeDB.execs))
+	}
+
+	want := "INSERT INTO accounts (first_name, last_name) VALUES (?, ?), (?, ?), (?, ?) [alice anderson bob baker carol clark]"
+	if fakeDB.execs[0] != want {
+		t.Fatalf("expected %q, got %q", want, fakeDB.execs[0])
+	}
+}
+
+func TestLineScanValue(t *testing.T) {
+	fakeDB.cols = []string{"rec"}
+	fakeDB.rows = [][]driver.Value{{"dave    dunn    50 "}}
+
+	db, err := sql.Open("flatfiletest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ln, err := BuildLine(testFmt, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.QueryRow("select rec from accounts").Scan(ln); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := ln.Get("first"); v != "dave" {
+		t.Fatalf("expected first %q, got %q", "dave", v)
+	}
+
+	v, err := ln.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v != "dave    dunn    50 " {
+		t.Fatalf("expected raw record back, got %q", v)
+	}
+
+	if err := ln.Scan(42); err == nil {
+		t.Fatal("expected error scanning a non-text value")
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	ff := newTestFile()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ff); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := New(testFmtr)
+	if err := gob.NewDecoder(&buf).Decode(decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ff.Equal(decoded) {
+		t.Fatalf("expected %q after round trip, got %q", ff.String(), decoded.String())
+	}
+
+	if v, err := decoded.Get(1, "last"); err != nil || v != "baker" {
+		t.Fatalf("expected key lookup to survive decoding, got %q, %v", v, err)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	ff := newTestFile()
+
+	var buf bytes.Buffer
+	if err := ff.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := New(testFmtr)
+	if err := decoded.ReadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ff.Equal(decoded) {
+		t.Fatalf("expected %q after round trip, got %q", ff.String(), decoded.String())
+	}
+
+	if v, err := decoded.Get(2, "first"); err != nil || v != "carol" {
+		t.Fatalf("expected key lookup to survive decoding, got %q, %v", v, err)
+	}
+
+	if err := decoded.ReadSnapshot(strings.NewReader("\xffjunk")); err == nil {
+		t.Fatal("expected error reading an unknown snapshot version")
+	}
+}
+
+func TestProtoExport(t *testing.T) {
+	want := "syntax = \"proto3\";\n\n" +
+		"message Account {\n" +
+		"  string first = 1;\n" +
+		"  string last = 2;\n" +
+		"  double balance = 3;\n" +
+		"}\n"
+	if got := ProtoSchema("Account", testFmt); got != want {
+		t.Fatalf("expected schema %q, got %q", want, got)
+	}
+
+	ff := newTestFile()
+	msg, err := ff.ProtoMessage(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantMsg := append([]byte("\x0a\x05alice\x12\x08anderson\x19"), make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(wantMsg[len(wantMsg)-8:], math.Float64bits(100))
+	if !bytes.Equal(msg, wantMsg) {
+		t.Fatalf("expected message % x, got % x", wantMsg, msg)
+	}
+
+	var buf bytes.Buffer
+	if err := ff.WriteProtoDelimited(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := binary.Uvarint(buf.Bytes()); int(n) != len(wantMsg) {
+		t.Fatalf("expected first delimiter %d, got %d", len(wantMsg), n)
+	}
+}
+
+func TestRecordBatch(t *testing.T) {
+	ff := newTestFile()
+	ff.Append("dave    dunn       ")
+
+	batch, err := ff.RecordBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if batch.NumRows != 4 || len(batch.Columns) != 3 {
+		t.Fatalf("expected 4 rows x 3 columns, got %d x %d", batch.NumRows, len(batch.Columns))
+	}
+
+	first := batch.Columns[0]
+	if first.Key != "first" || first.Type != Str || first.Strings[0] != "alice" || !first.Valid[0] {
+		t.Fatalf("unexpected first column %+v", first)
+	}
+
+	balance := batch.Columns[2]
+	if balance.Type != Num || balance.Numbers[1] != 25 || !balance.Valid[1] {
+		t.Fatalf("unexpected balance column %+v", balance)
+	}
+
+	// Dave's blank balance exports as a null.
+	if balance.Valid[3] {
+		t.Fatal("expected a blank value to be null")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := Generate("feed", "Account", testFmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"// Code generated by flatfile. DO NOT EDIT.",
+		"package feed",
+		"AccountFirstIndex    = 0",
+		"AccountBalanceLength = 3",
+		"AccountWidth = 19",
+		"type Account struct {",
+		"First   string",
+		"Balance float64",
+		"func ParseAccount(line string) (Account, error) {",
+		"func (v Account) Render() (string, error) {",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Fatalf("expected generated source to contain %q:\n%s", want, src)
+		}
+	}
+
+	if _, err := Generate("feed", "Empty", nil); err == nil {
+		t.Fatal("expected error generating from an empty layout")
+	}
+}
+
+func TestParseCopybook(t *testing.T) {
+	copybook := `
+      * Account record layout.
+      01 ACCOUNT-RECORD.
+         05 FIRST-NAME PIC X(8).
+         05 LAST-NAME  PICTURE X(8).
+         05 BALANCE    PIC 999.
+`
+
+	layout, err := ParseCopybook(strings.NewReader(copybook))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(layout) != 3 {
+		t.Fatalf("expected 3 formats, got %d", len(layout))
+	}
+
+	for i, want := range []Format{
+		NewFormat("first_name", 0, 8, Str),
+		NewFormat("last_name", 8, 8, Str),
+		NewFormat("balance", 16, 3, Num),
+	} {
+		f := layout[i]
+		if f.key != want.key || f.index != want.index || f.length != want.length || f.jsonType != want.jsonType {
+			t.Fatalf("format %d: got %+v, want %+v", i, f, want)
+		}
+	}
+
+	// The parsed layout drives the rest of the package.
+	ff := New(func(string) LineFmt { return layout })
+	ff.Append(testLines...)
+	if v, _ := ff.Get(0, "first_name"); v != "alice" {
+		t.Fatalf("expected first_name alice, got %q", v)
+	}
+
+	if _, err := ParseCopybook(strings.NewReader("05 BAD PIC Q(3).")); err == nil {
+		t.Fatal("expected error for an unsupported picture")
+	}
+}
+
+func TestGenerateFake(t *testing.T) {
+	layout := LineFmt{
+		NewFormat("first", 0, 8, Str),
+		NewFormat("status", 8, 1, Str).WithEnum(map[string]string{"A": "active", "C": "closed"}),
+		NewFormat("balance", 9, 3, Num),
+	}
+
+	ff := GenerateFake(layout, 25, 1)
+	if ff.Len() != 25 {
+		t.Fatalf("expected 25 lines, got %d", ff.Len())
+	}
+
+	for i := 0; i < ff.Len(); i++ {
+		if v, _ := ff.Get(i, "first"); len(v) == 0 || 8 < len(v) {
+			t.Fatalf("line %d: first %q does not fit its field", i, v)
+		}
+
+		if v, _ := ff.Get(i, "status"); v != "A" && v != "C" {
+			t.Fatalf("line %d: status %q is not an enum code", i, v)
+		}
+
+		v, _ := ff.Get(i, "balance")
+		if n, err := strconv.Atoi(v); err != nil || n < 0 || 999 < n {
+			t.Fatalf("line %d: balance %q does not fit its field", i, v)
+		}
+	}
+
+	// The same seed reproduces the same file.
+	if !equalFiles(ff, GenerateFake(layout, 25, 1)) {
+		t.Fatal("expected identical files for identical seeds")
+	}
+
+	if equalFiles(ff, GenerateFake(layout, 25, 2)) {
+		t.Fatal("expected different files for different seeds")
+	}
+}
+
+func TestParseSafe(t *testing.T) {
+	ln, err := ParseSafe(testFmtr, testLines[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := ln.Get("first"); v != "alice" {
+		t.Fatalf("expected first alice, got %q", v)
+	}
+
+	if _, err := ParseSafe(testFmtr, "too short"); err == nil {
+		t.Fatal("expected error parsing a short line")
+	}
+
+	panicky := func(string) LineFmt { panic("bad formatter") }
+	if _, err := ParseSafe(panicky, testLines[0]); err == nil {
+		t.Fatal("expected a formatter panic to come back as an error")
+	}
+}
+
+func FuzzParseSafe(f *testing.F) {
+	for _, line := range FuzzCorpus(testFmtr, 1) {
+		f.Add(line)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		ln, err := ParseSafe(testFmtr, line)
+		if err != nil {
+			return
+		}
+
+		// A parsed line renders at its input's width.
+		if ln.ByteLen() != len(line) {
+			t.Fatalf("parsed width %d from input of length %d", ln.ByteLen(), len(line))
+		}
+	})
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	clean := strings.Join(testLines, "\n") + "\n"
+	report, err := VerifyRoundTrip(strings.NewReader(clean), testFmtr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Lossless() || report.Lines != 3 {
+		t.Fatalf("expected 3 lossless lines, got %+v", report)
+	}
+
+	// Right-aligned numbers re-render left-aligned, so the layout is not
+	// lossless for them.
+	lossy := "alice   anderson100\nbob     baker    25\n"
+	report, err = VerifyRoundTrip(strings.NewReader(lossy), testFmtr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Diffs) != 1 {
+		t.Fatalf("expected 1 diff, got %+v", report)
+	}
+
+	d := report.Diffs[0]
+	if d.Line != 2 || d.Col != 17 || d.Got != "bob     baker   25 " {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+
+	if _, err := VerifyRoundTrip(strings.NewReader("short\n"), testFmtr); err == nil {
+		t.Fatal("expected error verifying an unparseable line")
+	}
+}
+
+func TestExplainLine(t *testing.T) {
+	want := "0000000000111111111\n" +
+		"0123456789012345678\n" +
+		"alice   anderson100\n" +
+		"[first ][last  ][b]\n" +
+		"first   [0:8] = \"alice\"\n" +
+		"last    [8:16] = \"anderson\"\n" +
+		"balance [16:19] = \"100\"\n"
+	if got := ExplainLine(testLines[0], testFmtr); got != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Short lines flag the overrunning formats instead of panicking.
+	got := ExplainLine("alice", testFmtr)
+	if !strings.Contains(got, "last    [8:16] overruns the line (length 5)") {
+		t.Fatalf("expected overrun notes, got:\n%s", got)
+	}
+}
+
+func TestDuplicateKeyError(t *testing.T) {
+	dup := LineFmt{
+		NewFormat("first", 0, 8, Str),
+		NewFormat("first", 8, 8, Str),
+	}
+
+	_, err := BuildLine(dup, nil)
+	var dke DuplicateKeyError
+	if !errors.As(err, &dke) || dke.Key != "first" {
+		t.Fatalf("expected a DuplicateKeyError for first, got %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected NewLine to panic on a duplicate key")
+		} else if _, ok := r.(DuplicateKeyError); !ok {
+			t.Fatalf("expected a DuplicateKeyError, got %v", r)
+		}
+	}()
+
+	NewLine(dup, testLines[0])
+}
+
+func TestNewLineSafe(t *testing.T) {
+	ln, err := NewLineSafe(testFmt, testLines[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := ln.Get("first"); v != "alice" {
+		t.Fatalf("expected first alice, got %q", v)
+	}
+
+	_, err = NewLineSafe(testFmt, "alice   ")
+	if err == nil || !strings.Contains(err.Error(), `"last"`) {
+		t.Fatalf("expected error naming the overrunning field, got %v", err)
+	}
+
+	dup := LineFmt{NewFormat("first", 0, 8, Str), NewFormat("first", 8, 8, Str)}
+	_, err = NewLineSafe(dup, testLines[0])
+	var dke DuplicateKeyError
+	if !errors.As(err, &dke) {
+		t.Fatalf("expected a DuplicateKeyError, got %v", err)
+	}
+}
+
+func TestReadFromLenient(t *testing.T) {
+	raw := "alice   anderson100\n" +
+		"garbage\n" +
+		"\n" +
+		"bob     baker   25 \n" +
+		"short\n"
+
+	ff := New(testFmtr)
+	report, err := ff.ReadFromLenient(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Read != 4 || report.Loaded != 2 || report.Skipped != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if len(report.Samples) != 2 || report.Samples[0].Line != 2 || report.Samples[0].Text != "garbage" {
+		t.Fatalf("unexpected samples %+v", report.Samples)
+	}
+
+	if report.Samples[1].Line != 5 || report.Samples[1].Err == nil {
+		t.Fatalf("unexpected samples %+v", report.Samples)
+	}
+
+	if ff.Len() != 2 {
+		t.Fatalf("expected 2 loaded lines, got %d", ff.Len())
+	}
+
+	if v, _ := ff.Get(1, "first"); v != "bob" {
+		t.Fatalf("expected first bob, got %q", v)
+	}
+}
+
+func TestRejectWriter(t *testing.T) {
+	raw := "alice   anderson100\ngarbage\nbob     baker   25 \n"
+
+	var rejects bytes.Buffer
+	ff := New(testFmtr).WithReject(&rejects)
+	report, err := ff.ReadFromLenient(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Loaded != 2 || report.Skipped != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	got := rejects.String()
+	if !strings.HasPrefix(got, "garbage | line 2: ") || !strings.HasSuffix(got, "\n") {
+		t.Fatalf("unexpected reject output %q", got)
+	}
+}
+
+func TestLineSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "source.txt")
+	if err := newTestFile().WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	ff := New(testFmtr)
+	if err := ff.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, lineNo := ff.Line(1).Source(); name != path || lineNo != 2 {
+		t.Fatalf("expected source %q line 2, got %q line %d", path, name, lineNo)
+	}
+
+	// Lines read from an anonymous reader keep their position but no name.
+	ff = New(testFmtr)
+	if _, err := ff.ReadFrom(strings.NewReader("\n" + testLines[0] + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, lineNo := ff.Line(0).Source(); name != "" || lineNo != 2 {
+		t.Fatalf("expected an unnamed source at line 2, got %q line %d", name, lineNo)
+	}
+
+	// Lines built in memory have no source.
+	if name, lineNo := newTestFile().Line(0).Source(); name != "" || lineNo != 0 {
+		t.Fatalf("expected no source, got %q line %d", name, lineNo)
+	}
+}
+
+func TestLineTags(t *testing.T) {
+	ln := NewLine(testFmt, testLines[0])
+	if _, ok := ln.Tag("state"); ok {
+		t.Fatal("expected no tag before SetTag")
+	}
+
+	ln.SetTag("state", "suspect")
+	ln.SetTag("batch", "b-17")
+	if v, ok := ln.Tag("state"); !ok || v != "suspect" {
+		t.Fatalf("expected tag suspect, got %q, %v", v, ok)
+	}
+
+	// Tags never reach the flat output.
+	if s := ln.String(); s != testLines[0] {
+		t.Fatalf("expected rendered line %q, got %q", testLines[0], s)
+	}
+
+	// Tags travel with copies and through JSON.
+	if v, ok := ln.Copy().Tag("batch"); !ok || v != "b-17" {
+		t.Fatalf("expected copied tag b-17, got %q, %v", v, ok)
+	}
+
+	b, err := ln.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Line
+	if err := decoded.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := decoded.Tag("state"); !ok || v != "suspect" {
+		t.Fatalf("expected tag to survive JSON, got %q, %v", v, ok)
+	}
+
+	if v, _ := decoded.Get("first"); v != "alice" {
+		t.Fatalf("expected first alice, got %q", v)
+	}
+
+	// Untagged lines keep the plain array form.
+	b, err = NewLine(testFmt, testLines[0]).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b[0] != '[' {
+		t.Fatalf("expected an array for an untagged line, got %s", b)
+	}
+}
+
+func TestFileMetadata(t *testing.T) {
+	ff := newTestFile()
+	ff.SetMeta("source", "corebank")
+	ff.SetMeta("schema", "v3")
+
+	if v, ok := ff.Meta("source"); !ok || v != "corebank" {
+		t.Fatalf("expected meta corebank, got %q, %v", v, ok)
+	}
+
+	// Metadata never reaches the flat output.
+	if ff.String() != strings.Join(testLines, "\n")+"\n" {
+		t.Fatalf("unexpected output %q", ff.String())
+	}
+
+	// Metadata survives JSON and binary snapshots.
+	b, err := ff.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromJSON FlatFile
+	if err := fromJSON.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := fromJSON.Meta("schema"); !ok || v != "v3" {
+		t.Fatalf("expected meta to survive JSON, got %q, %v", v, ok)
+	}
+
+	var buf bytes.Buffer
+	if err := ff.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	fromSnap := New(testFmtr)
+	if err := fromSnap.ReadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := fromSnap.Meta("source"); !ok || v != "corebank" || !ff.Equal(fromSnap) {
+		t.Fatalf("expected meta to survive the snapshot, got %q, %v", v, ok)
+	}
+}
+
+func TestMetaFromHeader(t *testing.T) {
+	hdrFmt := LineFmt{
+		NewFormat("system", 0, 8, Str),
+		NewFormat("version", 8, 8, Str),
+	}
+
+	ff := New(testFmtr)
+	ff.Append("corebankv3         ")
+	ff.AppendLines(NewLine(testFmt, testLines[0]))
+
+	if err := ff.MetaFromHeader(hdrFmt); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := ff.Meta("system"); !ok || v != "corebank" {
+		t.Fatalf("expected meta corebank, got %q, %v", v, ok)
+	}
+
+	if ff.Len() != 1 {
+		t.Fatalf("expected the header line to be removed, got %d lines", ff.Len())
+	}
+
+	if err := New(testFmtr).MetaFromHeader(hdrFmt); err == nil {
+		t.Fatal("expected error promoting an empty file's header")
+	}
+}
+
+func TestFlatFileSet(t *testing.T) {
+	orderFmt := LineFmt{
+		NewFormat("order", 0, 6, Str),
+		NewFormat("last", 6, 8, Str),
+	}
+
+	dir := t.TempDir()
+	custPath := filepath.Join(dir, "customers.txt")
+	orderPath := filepath.Join(dir, "orders.txt")
+	if err := newTestFile().WriteFile(custPath); err != nil {
+		t.Fatal(err)
+	}
+
+	orders := New(StaticFormatter(orderFmt...))
+	orders.Append("o-1   baker   ", "o-2   clark   ")
+	if err := orders.WriteFile(orderPath); err != nil {
+		t.Fatal(err)
+	}
+
+	set := NewSet()
+	set.Define("customers", testFmtr)
+	set.Define("orders", StaticFormatter(orderFmt...))
+
+	if err := set.Load(map[string]string{"customers": custPath, "orders": orderPath}); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := set.Names(); len(names) != 2 || names[0] != "customers" {
+		t.Fatalf("unexpected names %v", names)
+	}
+
+	if ff, ok := set.File("orders"); !ok || ff.Len() != 2 {
+		t.Fatalf("expected 2 orders, got %v, %v", ff, ok)
+	}
+
+	// Every order's last name resolves to a customer.
+	if err := set.CrossCheck("customers", "orders", "last"); err != nil {
+		t.Fatal(err)
+	}
+
+	orders, _ = set.File("orders")
+	orders.Append("o-3   nobody  ")
+	if err := set.CrossCheck("customers", "orders", "last"); err == nil {
+		t.Fatal("expected a cross-check failure for an unknown last name")
+	}
+
+	err := set.Validate(func(name string, ff *FlatFile) error {
+		if ff.Len() == 0 {
+			return errors.New("empty")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set.Define("empty", testFmtr)
+	err = set.Validate(func(name string, ff *FlatFile) error {
+		if ff.Len() == 0 {
+			return errors.New("empty")
+		}
+
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "empty: empty") {
+		t.Fatalf("expected a named validation failure, got %v", err)
+	}
+}
+
+func TestWritePartitioned(t *testing.T) {
+	ff := newTestFile()
+	ff.Append("dave    dunn    100")
+
+	trailerFmt := LineFmt{
+		NewFormat("tag", 0, 1, Str),
+		NewFormat("count", 1, 4, Num),
+	}
+	ff.WithTrailer(trailerFmt, func(stats FileStats) map[string]string {
+		return map[string]string{"tag": "T", "count": strconv.Itoa(stats.Lines)}
+	})
+
+	dir := t.TempDir()
+	if err := ff.WritePartitioned(dir, "balance"); err != nil {
+		t.Fatal(err)
+	}
+
+	for value, want := range map[string]string{
+		"100": "alice   anderson100\ndave    dunn    100\nT2   \n",
+		"25":  "bob     baker   25 \nT1   \n",
+		"75":  "carol   clark   75 \nT1   \n",
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, value+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != want {
+			t.Fatalf("partition %s: expected %q, got %q", value, want, b)
+		}
+	}
+}
+
+func TestSplitFiles(t *testing.T) {
+	ff := newTestFile()
+	prefix := filepath.Join(t.TempDir(), "chunk")
+	paths, err := ff.SplitFiles(prefix, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paths) != 2 || paths[0] != prefix+"-0001.txt" || paths[1] != prefix+"-0002.txt" {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+
+	b, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := testLines[0] + "\n" + testLines[1] + "\n"; string(b) != want {
+		t.Fatalf("chunk 1: expected %q, got %q", want, b)
+	}
+
+	b, err = os.ReadFile(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := testLines[2] + "\n"; string(b) != want {
+		t.Fatalf("chunk 2: expected %q, got %q", want, b)
+	}
+
+	if _, err := ff.SplitFiles(prefix, 0); err == nil {
+		t.Fatal("expected error splitting into zero-line files")
+	}
+
+	if paths, err := New(testFmtr).SplitFiles(prefix, 2); err != nil || len(paths) != 0 {
+		t.Fatalf("expected no chunks for an empty file, got %v, %v", paths, err)
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, lines := range map[string][]string{
+		"drop-02.txt": {testLines[2]},
+		"drop-01.txt": {testLines[0], testLines[1]},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Overlapping patterns read each file once, in sorted path order.
+	ff := New(testFmtr)
+	if err := ff.ReadFiles(filepath.Join(dir, "drop-*.txt"), filepath.Join(dir, "*.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalFiles(ff, newTestFile()) {
+		t.Fatalf("expected %q, got %q", newTestFile().String(), ff.String())
+	}
+
+	if name, lineNo := ff.Line(2).Source(); name != filepath.Join(dir, "drop-02.txt") || lineNo != 1 {
+		t.Fatalf("unexpected source %q line %d", name, lineNo)
+	}
+
+	if err := New(testFmtr).ReadFiles(filepath.Join(dir, "missing-*.txt")); err == nil {
+		t.Fatal("expected error for a pattern matching no files")
+	}
+}
+
+func TestReadZipTar(t *testing.T) {
+	var zbuf bytes.Buffer
+	zw := zip.NewWriter(&zbuf)
+	for name, body := range map[string]string{
+		"feed-2.txt": testLines[2] + "\n",
+		"feed-1.txt": testLines[0] + "\n" + testLines[1] + "\n",
+		"readme.md":  "not a feed\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ff := New(testFmtr)
+	if err := ff.ReadZip(bytes.NewReader(zbuf.Bytes()), int64(zbuf.Len()), "feed-*.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalFiles(ff, newTestFile()) {
+		t.Fatalf("expected %q, got %q", newTestFile().String(), ff.String())
+	}
+
+	if name, lineNo := ff.Line(2).Source(); name != "feed-2.txt" || lineNo != 1 {
+		t.Fatalf("unexpected source %q line %d", name, lineNo)
+	}
+
+	if err := New(testFmtr).ReadZip(bytes.NewReader(zbuf.Bytes()), int64(zbuf.Len()), "*.csv"); err == nil {
+		t.Fatal("expected error for a pattern matching no members")
+	}
+
+	var tbuf bytes.Buffer
+	tw := tar.NewWriter(&tbuf)
+	body := strings.Join(testLines, "\n") + "\n"
+	if err := tw.WriteHeader(&tar.Header{Name: "feed.txt", Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.WriteString(tw, body); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ff = New(testFmtr)
+	if err := ff.ReadTar(bytes.NewReader(tbuf.Bytes()), "feed.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalFiles(ff, newTestFile()) {
+		t.Fatalf("expected %q, got %q", newTestFile().String(), ff.String())
+	}
+}
+
+// memStorage is an in-memory Storage standing in for a remote backend.
+type memStorage map[string]*bytes.Buffer
+
+func (m memStorage) Open(name string) (io.ReadCloser, error) {
+	buf, ok := m[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+}
+
+func (m memStorage) Create(name string) (io.WriteCloser, error) {
+	buf := &bytes.Buffer{}
+	m[name] = buf
+	return nopWriteCloser{buf}, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestStorage(t *testing.T) {
+	store := memStorage{}
+	if err := newTestFile().WriteStorage(store, "out.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	ff := New(testFmtr)
+	if err := ff.ReadStorage(store, "out.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalFiles(ff, newTestFile()) {
+		t.Fatalf("expected %q, got %q", newTestFile().String(), ff.String())
+	}
+
+	if name, lineNo := ff.Line(0).Source(); name != "out.txt" || lineNo != 1 {
+		t.Fatalf("unexpected source %q line %d", name, lineNo)
+	}
+
+	dir := t.TempDir()
+	if err := newTestFile().WriteStorage(DiskStorage{Root: dir}, "out.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	ff = New(testFmtr)
+	if err := ff.ReadStorage(DiskStorage{Root: dir}, "out.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalFiles(ff, newTestFile()) {
+		t.Fatalf("expected %q, got %q", newTestFile().String(), ff.String())
+	}
+}
+
+func TestReader(t *testing.T) {
+	ff := newTestFile()
+	b, err := io.ReadAll(ff.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != ff.String() {
+		t.Fatalf("expected %q, got %q", ff.String(), string(b))
+	}
+
+	// One byte at a time exercises the partial-line buffer.
+	var (
+		one  = make([]byte, 1)
+		r    = ff.Reader()
+		slow []byte
+	)
+
+	for {
+		n, err := r.Read(one)
+		slow = append(slow, one[:n]...)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if string(slow) != ff.String() {
+		t.Fatalf("expected %q, got %q", ff.String(), string(slow))
+	}
+
+	if b, err := io.ReadAll(New(testFmtr).Reader()); err != nil || 0 < len(b) {
+		t.Fatalf("expected an empty read, got %q, %v", string(b), err)
+	}
+}
+
+func TestOpenIndexed(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "data.txt")
+		idx  = filepath.Join(dir, "data.idx")
+	)
+
+	if err := os.WriteFile(path, []byte(testLines[0]+"\n\n"+testLines[1]+"\n"+testLines[2]+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteIndex(path, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenIndexed(testFmtr, path, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.Len() != len(testLines) {
+		t.Fatalf("expected %d lines, got %d", len(testLines), f.Len())
+	}
+
+	for i := f.Len() - 1; 0 <= i; i-- {
+		ln, err := f.Line(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ln.String() != testLines[i] {
+			t.Fatalf("expected %q, got %q", testLines[i], ln.String())
+		}
+	}
+
+	if _, err := f.Line(f.Len()); err == nil {
+		t.Fatal("expected an out-of-range error")
+	}
+}
+
+func TestIndexedCache(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		path = filepath.Join(dir, "data.txt")
+		idx  = filepath.Join(dir, "data.idx")
+	)
+
+	if err := newTestFile().WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteIndex(path, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenIndexed(testFmtr, path, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f = f.WithCache(1)
+	for _, i := range []int{0, 1} {
+		if _, err := f.Line(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Closing the file leaves only cached lines readable: line 1 is
+	// cached, line 0 was evicted by the capacity of one.
+	f.Close()
+	ln, err := f.Line(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ln.String() != testLines[1] {
+		t.Fatalf("expected %q, got %q", testLines[1], ln.String())
+	}
+
+	if _, err := f.Line(0); err == nil {
+		t.Fatal("expected a read error for an evicted line")
+	}
+
+	// Mutating a returned line must not corrupt the cache.
+	if err := ln.Set("first", "mallory"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ln, err := f.Line(1); err != nil || ln.String() != testLines[1] {
+		t.Fatalf("expected %q, got %q, %v", testLines[1], ln.String(), err)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	ff := newTestFile()
+	b, err := ff.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := New(testFmtr)
+	if err := dec.DecodeJSON(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	if dec.Len() != ff.Len() {
+		t.Fatalf("expected %d lines, got %d", ff.Len(), dec.Len())
+	}
+
+	for i := 0; i < ff.Len(); i++ {
+		if got, err := dec.Get(i, "first"); err != nil {
+			t.Fatal(err)
+		} else if want, _ := ff.Get(i, "first"); got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+
+	ff.SetMeta("feed", "core")
+	if b, err = ff.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec = New(testFmtr)
+	if err := dec.DecodeJSON(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := dec.Meta("feed"); !ok || v != "core" {
+		t.Fatalf("expected metadata to survive decoding, got %q, %t", v, ok)
+	}
+
+	if dec.Len() != ff.Len() {
+		t.Fatalf("expected %d lines, got %d", ff.Len(), dec.Len())
+	}
+
+	if err := New(testFmtr).DecodeJSON(strings.NewReader(`"nope"`)); err == nil {
+		t.Fatal("expected an error for a non-array payload")
+	}
+}
+
+func TestMarshalJSONWith(t *testing.T) {
+	ff := newTestFile()
+	plain, err := ff.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ff.MarshalJSONWith(MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, plain) {
+		t.Fatalf("expected zero options to match MarshalJSON, got %q", string(b))
+	}
+
+	b, err = ff.MarshalJSONWith(MarshalOptions{OmitLayout: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(b, []byte(`"index"`)) || bytes.Contains(b, []byte(`"length"`)) {
+		t.Fatalf("expected layout to be omitted, got %q", string(b))
+	}
+
+	b, err = ff.MarshalJSONWith(MarshalOptions{Objects: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recs []map[string]any
+	if err := json.Unmarshal(b, &recs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recs) != ff.Len() {
+		t.Fatalf("expected %d records, got %d", ff.Len(), len(recs))
+	}
+
+	if v, ok := recs[0]["first"]; !ok || v != "alice" {
+		t.Fatalf("expected first %q, got %v", "alice", v)
+	}
+
+	b, err = ff.MarshalJSONWith(MarshalOptions{Objects: true, Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte("\n  ")) {
+		t.Fatalf("expected indented output, got %q", string(b))
+	}
+}
+
+func TestJSONTypeObjectArray(t *testing.T) {
+	f := NewField(NewFormat("payload", 0, 24, Object), `{"a":1}`)
+	b, err := f.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":{"a":1}`)) {
+		t.Fatalf("expected an embedded object, got %q", string(b))
+	}
+
+	f = NewField(NewFormat("codes", 0, 24, Array), `[1,2,3]`)
+	if b, err = f.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":[1,2,3]`)) {
+		t.Fatalf("expected an embedded array, got %q", string(b))
+	}
+
+	// Invalid embedded JSON falls back to a quoted string; blank is
+	// null.
+	f = NewField(NewFormat("payload", 0, 24, Object), `{"a":`)
+	if b, err = f.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":"{\"a\":"`)) {
+		t.Fatalf("expected a quoted fallback, got %q", string(b))
+	}
+
+	f = NewField(NewFormat("payload", 0, 24, Object), "   ")
+	if b, err = f.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":null`)) {
+		t.Fatalf("expected null, got %q", string(b))
+	}
+
+	if jt := parseJSONType(Object.String()); jt != Object {
+		t.Fatalf("expected %d, got %d", Object, jt)
+	}
+
+	if jt := parseJSONType(Array.String()); jt != Array {
+		t.Fatalf("expected %d, got %d", Array, jt)
+	}
+}
+
+func TestTimestampJSON(t *testing.T) {
+	fmt0 := NewFormat("asof", 0, 8, Timestamp).WithTimeLayout("20060102")
+	f := NewField(fmt0, "20240131")
+	b, err := f.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":"2024-01-31T00:00:00Z"`)) {
+		t.Fatalf("expected an RFC 3339 value, got %q", string(b))
+	}
+
+	var f1 Field
+	if err := f1.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if f1.Value() != "20240131" {
+		t.Fatalf("expected the native layout back, got %q", f1.Value())
+	}
+
+	// A value that doesn't parse falls back to a quoted string; blank
+	// is null.
+	f = NewField(fmt0, "notadate")
+	if b, err = f.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":"notadate"`)) {
+		t.Fatalf("expected a quoted fallback, got %q", string(b))
+	}
+
+	f = NewField(fmt0, "        ")
+	if b, err = f.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":null`)) {
+		t.Fatalf("expected null, got %q", string(b))
+	}
+}
+
+func TestFieldMarshaler(t *testing.T) {
+	labels := FieldMarshalerFunc(func(f Field) ([]byte, error) {
+		switch f.Value() {
+		case "A":
+			return []byte("active"), nil
+		case "C":
+			return []byte("closed"), nil
+		default:
+			return nil, fmt.Errorf("unknown status %q", f.Value())
+		}
+	})
+
+	f := NewField(NewFormat("status", 0, 1, Str).WithMarshaler(labels), "A")
+	b, err := f.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":"active"`)) {
+		t.Fatalf("expected the marshaled label, got %q", string(b))
+	}
+
+	f = NewField(NewFormat("status", 0, 1, Str).WithMarshaler(labels), "X")
+	if _, err := f.MarshalJSON(); err == nil {
+		t.Fatal("expected the marshaler's error to surface")
+	}
+
+	// Valid JSON from a marshaler embeds verbatim.
+	raw := FieldMarshalerFunc(func(f Field) ([]byte, error) {
+		return []byte(`{"code":"` + f.Value() + `"}`), nil
+	})
+
+	f = NewField(NewFormat("status", 0, 1, Str).WithMarshaler(raw), "A")
+	if b, err = f.MarshalJSON(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"value":{"code":"A"}`)) {
+		t.Fatalf("expected embedded JSON, got %q", string(b))
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("letter").Parse("Dear {{.first}} {{.last}}, your balance is {{.balance}}.\n"))
+	var buf bytes.Buffer
+	if err := newTestFile().RenderTemplate(&buf, tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Dear alice anderson, your balance is 100.\n" +
+		"Dear bob baker, your balance is 25.\n" +
+		"Dear carol clark, your balance is 75.\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+
+	tmpl = template.Must(template.New("bad").Option("missingkey=error").Parse("{{.missing}}"))
+	if err := newTestFile().RenderTemplate(io.Discard, tmpl); err == nil {
+		t.Fatal("expected an error for a missing key")
+	} else if !strings.Contains(err.Error(), "line 0") {
+		t.Fatalf("expected the error to name the line, got %v", err)
+	}
+}
+
+func TestDDL(t *testing.T) {
+	layout := []Format{
+		NewFormat("first", 0, 8, Str),
+		NewFormat("balance", 8, 3, Num),
+		NewFormat("active", 11, 1, Bool),
+		NewFormat("asof", 12, 8, Timestamp),
+		NewFormat("payload", 20, 64, Object),
+	}
+
+	want := "CREATE TABLE accounts (\n" +
+		"  first CHAR(8),\n" +
+		"  balance NUMERIC(3),\n" +
+		"  active BOOLEAN,\n" +
+		"  asof TIMESTAMP,\n" +
+		"  payload JSONB\n" +
+		");\n"
+	if got := DDL("accounts", layout, "postgres"); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	got := DDL("accounts", layout, "mysql")
+	for _, want := range []string{"DECIMAL(3)", "payload JSON\n"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in %q", want, got)
+		}
+	}
+
+	if got := DDL("accounts", layout, ""); !strings.Contains(got, "payload VARCHAR(64)") {
+		t.Fatalf("expected a portable fallback, got %q", got)
+	}
+}
+
+func TestRegexpFormatter(t *testing.T) {
+	fmtr := RegexpFormatter(regexp.MustCompile(`^([a-z]+)\s+([a-z]+)\s*(\d+)`), "first", "last", "balance")
+	ff := New(fmtr)
+	ff.Append(testLines...)
+	for i, want := range [][3]string{
+		{"alice", "anderson", "100"},
+		{"bob", "baker", "25"},
+		{"carol", "clark", "75"},
+	} {
+		for j, key := range []string{"first", "last", "balance"} {
+			if v, err := ff.Get(i, key); err != nil {
+				t.Fatal(err)
+			} else if v != want[j] {
+				t.Fatalf("line %d, key %q: expected %q, got %q", i, key, want[j], v)
+			}
+		}
+	}
+
+	// Unnamed extra groups fall back to generated keys; unmatched lines
+	// parse as empty.
+	fmtr = RegexpFormatter(regexp.MustCompile(`^([a-z]+)\s+(?P<surname>[a-z]+)\s*(\d+)`), "first")
+	ln, err := ParseSafe(fmtr, testLines[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"first", "surname", "group3"} {
+		if _, err := ln.Get(key); err != nil {
+			t.Fatalf("expected key %q: %v", key, err)
+		}
+	}
+
+	if ln, err := ParseSafe(fmtr, "12345"); err != nil || ln.Len() != 0 {
+		t.Fatalf("expected an empty line for an unmatched pattern, got %d fields, %v", ln.Len(), err)
+	}
+}
+
+func TestRaggedFormat(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("code", 0, 4, Str),
+		NewFormat("note", 4, 8, Str).Ragged(),
+	}
+
+	for _, tc := range []struct {
+		line, note string
+	}{
+		{"AAAAshort", "short"},
+		{"BBBBa note much longer than eight bytes", "a note much longer than eight bytes"},
+		{"CCCC", ""},
+	} {
+		ln, err := NewLineSafe(lf, tc.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v, err := ln.Get("note"); err != nil || v != tc.note {
+			t.Fatalf("line %q: expected note %q, got %q, %v", tc.line, tc.note, v, err)
+		}
+	}
+
+	// A long value still renders in full, and a short one pads to the
+	// declared minimum.
+	ln, _ := NewLineSafe(lf, "AAAAshort")
+	if err := ln.Set("note", "well past the minimum"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ln.String(); got != "AAAAwell past the minimum" {
+		t.Fatalf("expected %q, got %q", "AAAAwell past the minimum", got)
+	}
+
+	if _, err := NewLineSafe(lf, "AAA"); err == nil {
+		t.Fatal("expected an error for a line ending before the ragged field")
+	}
+}
+
+func TestTabExpansion(t *testing.T) {
+	// Tab stops every 8 columns line the tabbed input up with the
+	// fixed-width layout.
+	ff := New(testFmtr).WithTabWidth(8)
+	if _, err := ff.ReadFrom(strings.NewReader("alice\tanderson100\nbob\tbaker\t25 \n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range [][2]string{{"alice", "anderson"}, {"bob", "baker"}} {
+		if v, err := ff.Get(i, "first"); err != nil || v != want[0] {
+			t.Fatalf("line %d: expected first %q, got %q, %v", i, want[0], v, err)
+		}
+
+		if v, err := ff.Get(i, "last"); err != nil || v != want[1] {
+			t.Fatalf("line %d: expected last %q, got %q, %v", i, want[1], v, err)
+		}
+	}
+
+	if got := expandTabs("a\tb", 4); got != "a   b" {
+		t.Fatalf("expected %q, got %q", "a   b", got)
+	}
+
+	if got := expandTabs("a\tb", 0); got != "a\tb" {
+		t.Fatalf("expected tabs untouched at width 0, got %q", got)
+	}
+}
+
+func TestRecordLength(t *testing.T) {
+	ff := newTestFile().WithRecordLength(19, WidthError)
+	var buf bytes.Buffer
+	if _, err := ff.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != strings.Join(testLines, "\n")+"\n" {
+		t.Fatalf("expected %q, got %q", strings.Join(testLines, "\n")+"\n", buf.String())
+	}
+
+	ff = newTestFile().WithRecordLength(25, WidthError)
+	if _, err := ff.WriteTo(io.Discard); err == nil {
+		t.Fatal("expected an error for lines missing the record length")
+	} else if !strings.Contains(err.Error(), "line 0") {
+		t.Fatalf("expected the error to name the line, got %v", err)
+	}
+
+	// WidthFix pads short lines and truncates long ones.
+	ff = newTestFile().WithRecordLength(21, WidthFix)
+	buf.Reset()
+	if _, err := ff.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if len(line) != 21 {
+			t.Fatalf("line %d: expected 21 bytes, got %d", i, len(line))
+		}
+	}
+
+	ff = newTestFile().WithRecordLength(10, WidthFix)
+	buf.Reset()
+	if _, err := ff.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Split(buf.String(), "\n")[0]; got != testLines[0][:10] {
+		t.Fatalf("expected %q, got %q", testLines[0][:10], got)
+	}
+}
+
+func TestWidthMode(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("code", 0, 4, Str),
+		NewFormat("note", 4, 4, Str).Ragged(),
+	}
+
+	// Shortening a ragged value leaves the captured length behind as
+	// trailing spaces unless the line renders extent-exact.
+	ln := NewLine(lf, "AAAAa long trailing note")
+	if err := ln.Set("note", "ok"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ln.String(); got != "AAAAok                  " {
+		t.Fatalf("expected the captured width, got %q", got)
+	}
+
+	ln.SetWidthMode(WidthExtents)
+	if got := ln.String(); got != "AAAAok  " {
+		t.Fatalf("expected the extent width, got %q", got)
+	}
+
+	// The file-level mode covers existing and appended lines.
+	ff := newTestFile().WithWidthMode(WidthExtents)
+	ff.Append(testLines[0])
+	if ff.String() != strings.Join(testLines, "\n")+"\n"+testLines[0]+"\n" {
+		t.Fatalf("unexpected render %q", ff.String())
+	}
+
+	for i := 0; i < ff.Len(); i++ {
+		if err := ff.Set(i, "balance", "1"); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ff.Line(i).String(); len(got) != 19 {
+			t.Fatalf("line %d: expected 19 bytes, got %d: %q", i, len(got), got)
+		}
+	}
+}
+
+func TestSnapshotWriteKeepsDirty(t *testing.T) {
+	ff := newTestFile()
+	ff.markClean()
+	if err := ff.Set(1, "first", "bill"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing a snapshot must not clear dirty flags on lines still
+	// shared with the original.
+	snap := ff.Snapshot()
+	if _, err := snap.WriteTo(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	if dirty := ff.Dirty(); len(dirty) != 1 || dirty[0] != 1 {
+		t.Fatalf("expected line 1 to stay dirty, got %v", dirty)
+	}
+
+	if dirty := snap.Dirty(); dirty != nil {
+		t.Fatalf("expected the snapshot to be clean, got %v", dirty)
+	}
+}
+
+func TestGobKeepsLayoutExtras(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("asof", 0, 8, Timestamp).WithTimeLayout("20060102"),
+		NewFormat("note", 8, 4, Str).Ragged(),
+	}
+
+	ff := New(StaticFormatter(lf...))
+	if _, err := ff.ReadFrom(strings.NewReader("20240131a longer note\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	ff.lines[0].SetTag("batch", "7")
+	ff.SetMeta("feed", "core")
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ff); err != nil {
+		t.Fatal(err)
+	}
+
+	var dec FlatFile
+	if err := gob.NewDecoder(&buf).Decode(&dec); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ragged flag, time layout, tags, source, and metadata all
+	// survive the round trip.
+	ln := dec.lines[0]
+	if err := ln.Set("note", "a different longer note"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ln.fields[0].MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"2024-01-31T00:00:00Z"`)) {
+		t.Fatalf("expected the time layout to survive, got %q", string(b))
+	}
+
+	if v, ok := ln.Tag("batch"); !ok || v != "7" {
+		t.Fatalf("expected tag batch=7, got %q, %t", v, ok)
+	}
+
+	if name, lineNo := ln.Source(); name != "" || lineNo != 1 {
+		t.Fatalf("unexpected source %q line %d", name, lineNo)
+	}
+
+	if v, ok := dec.Meta("feed"); !ok || v != "core" {
+		t.Fatalf("expected metadata to survive, got %q, %t", v, ok)
+	}
+}
+
+func TestSnapshotKeepsLayoutExtras(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("asof", 0, 8, Timestamp).WithTimeLayout("20060102"),
+		NewFormat("note", 8, 4, Str).Ragged(),
+	}
+
+	ff := New(StaticFormatter(lf...))
+	ff.Append("20240131a longer note")
+
+	var buf bytes.Buffer
+	if err := ff.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := New(StaticFormatter(lf...))
+	if err := dec.ReadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ln := dec.lines[0]
+	if err := ln.Set("note", "a different longer note"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ln.fields[0].MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(`"2024-01-31T00:00:00Z"`)) {
+		t.Fatalf("expected the time layout to survive, got %q", string(b))
+	}
+}
+
+func TestRaggedReparse(t *testing.T) {
+	lf := LineFmt{
+		NewFormat("code", 0, 4, Str),
+		NewFormat("note", 4, 4, Str).Ragged(),
+	}
+
+	// SetRaw mirrors parsing: a ragged tail may end short of the format
+	// length or run past it, taking the rest of the text either way.
+	ln := NewLine(lf, "AAAAa much longer note")
+	if err := ln.SetRaw("BBBBx"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := ln.Get("note"); v != "x" {
+		t.Fatalf("expected the short ragged tail, got %q", v)
+	}
+
+	if err := ln.SetRaw("CCCCa replacement tail"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := ln.Get("note"); v != "a replacement tail" {
+		t.Fatalf("expected the full ragged tail, got %q", v)
+	}
+
+	ff := New(StaticFormatter(lf...))
+	ff.Append("AAAAnote")
+	if err := ff.SetStr(0, "DDDDy"); err != nil {
+		t.Fatal(err)
+	}
+
+	anom, err := Analyze(strings.NewReader("EEEEz\n"), StaticFormatter(lf...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if anom.Rejected != nil {
+		t.Fatalf("expected no rejected lines, got %v", anom.Rejected)
+	}
+}
+
+func TestReaderEnforcesRecordLength(t *testing.T) {
+	ff := newTestFile().WithRecordLength(25, WidthFix)
+
+	var buf bytes.Buffer
+	if _, err := ff.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := io.ReadAll(ff.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, buf.Bytes()) {
+		t.Fatalf("expected the reader to render as WriteTo, got %q", string(b))
+	}
+}
+
+func TestWriteDirtyEnforcesRecordLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dirty.txt")
+	ff := newTestFile().WithRecordLength(25, WidthFix)
+	if err := ff.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ff.Set(1, "balance", "999"); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ff.WriteDirty(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want bytes.Buffer
+	if _, err := ff.WriteTo(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, want.Bytes()) {
+		t.Fatalf("expected %q after incremental write, got %q", want.String(), string(b))
+	}
+}
+
+func TestLenientLoadStampsSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lenient.txt")
+	if err := os.WriteFile(path, []byte(testLines[0]+"\nshort\n"+testLines[1]+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ff := New(testFmtr)
+	if _, err := ff.ReadFileLenient(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The skipped line still advances the line number, so the stamp
+	// points at the original input location.
+	if name, lineNo := ff.Line(1).Source(); name != path || lineNo != 3 {
+		t.Fatalf("expected source %q line 3, got %q line %d", path, name, lineNo)
+	}
+
+	// Lines read from an anonymous reader keep their position but no name.
+	ff = New(testFmtr)
+	if _, err := ff.ReadFromLenient(strings.NewReader("\n" + testLines[0] + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, lineNo := ff.Line(0).Source(); name != "" || lineNo != 2 {
+		t.Fatalf("expected an unnamed source at line 2, got %q line %d", name, lineNo)
+	}
+}
+
+func TestNegativeCounts(t *testing.T) {
+	ff := newTestFile()
+	if n := ff.Sample(-1, 1).Len(); n != 0 {
+		t.Fatalf("expected an empty sample, got %d lines", n)
+	}
+
+	if n := ff.Head(-1).Len(); n != 0 {
+		t.Fatalf("expected an empty head, got %d lines", n)
+	}
+
+	if n := ff.Tail(-1).Len(); n != 0 {
+		t.Fatalf("expected an empty tail, got %d lines", n)
+	}
+
+	ff.Truncate(-1)
+	if n := ff.Len(); n != 0 {
+		t.Fatalf("expected an empty file after truncating, got %d lines", n)
+	}
+}
+
+func TestSafeFlatFile(t *testing.T) {
+	sff := NewSafe(testFmtr)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, line := range testLines {
+				sff.Append(line)
+				_ = sff.Len()
+				_, _ = sff.Get(0, "first")
+			}
+		}()
+	}
+
+	wg.Wait()
+	if n := sff.Len(); n != 8*len(testLines) {
+		t.Fatalf("Len: got %d, want %d", n, 8*len(testLines))
+	}
+
+	sff.Update(func(ff *FlatFile) {
+		for 0 < ff.Len() {
+			ff.Remove(0)
+		}
+	})
+
+	if n := sff.Len(); n != 0 {
+		t.Fatalf("Len after Update: got %d, want 0", n)
+	}
+}
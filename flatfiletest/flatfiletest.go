@@ -0,0 +1,110 @@
+// Package flatfiletest provides assertion helpers for testing code
+// built on package flatfile.
+package flatfiletest
+
+import (
+	"strings"
+
+	"github.com/nathangreene3/flatfile"
+)
+
+// A TB is the subset of testing.TB the assertions need, kept as an
+// interface so failure reporting itself can be tested.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// AssertEqualFiles fails t with a field-level diff when two flat files
+// differ. Lines are compared by parsed field values rather than raw
+// bytes, so differences in trailing padding and line-ending conventions
+// do not fail the assertion.
+func AssertEqualFiles(t TB, a, b *flatfile.FlatFile) {
+	t.Helper()
+
+	if a.Len() != b.Len() {
+		t.Errorf("flat files hold %d and %d lines", a.Len(), b.Len())
+	}
+
+	n := a.Len()
+	if b.Len() < n {
+		n = b.Len()
+	}
+
+	for i := 0; i < n; i++ {
+		var (
+			kvsA = a.KeyValues(i)
+			kvsB = b.KeyValues(i)
+		)
+
+		for key, va := range kvsA {
+			vb, ok := kvsB[key]
+			if !ok {
+				t.Errorf("line %d: field %q is missing from the second file", i, key)
+				continue
+			}
+
+			if va != vb {
+				t.Errorf("line %d: field %q: %q != %q", i, key, va, vb)
+			}
+		}
+
+		for key := range kvsB {
+			if _, ok := kvsA[key]; !ok {
+				t.Errorf("line %d: field %q is missing from the first file", i, key)
+			}
+		}
+	}
+}
+
+// A RecordingFormatter wraps a formatter and captures every line it is
+// asked to parse, for asserting on what code under test fed its
+// formatter.
+type RecordingFormatter struct {
+	fmtr  flatfile.Formatter
+	lines []string
+}
+
+// Record wraps a formatter so the lines it parses can be inspected with
+// Lines.
+func Record(fmtr flatfile.Formatter) *RecordingFormatter {
+	return &RecordingFormatter{fmtr: fmtr}
+}
+
+// Formatter returns the wrapped formatter, which records each line
+// before delegating.
+func (rf *RecordingFormatter) Formatter() flatfile.Formatter {
+	return func(line string) flatfile.LineFmt {
+		rf.lines = append(rf.lines, line)
+		return rf.fmtr(line)
+	}
+}
+
+// Lines returns a copy of the lines parsed so far, in order.
+func (rf *RecordingFormatter) Lines() []string {
+	return append([]string(nil), rf.lines...)
+}
+
+// Load parses a raw dump — such as the contents of a golden file — into
+// a flat file, tolerating \r\n line endings and a missing final newline.
+// It fails t on lines too short for the layout the formatter assigns.
+func Load(t TB, fmtr flatfile.Formatter, raw string) *flatfile.FlatFile {
+	t.Helper()
+
+	ff := flatfile.New(fmtr)
+	for i, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+
+		if _, err := flatfile.ParseSafe(fmtr, line); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+			return ff
+		}
+
+		ff.Append(line)
+	}
+
+	return ff
+}
@@ -0,0 +1,81 @@
+package flatfiletest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nathangreene3/flatfile"
+)
+
+// testFmt mirrors the layout used by the parent package's tests.
+var testFmt = flatfile.LineFmt{
+	flatfile.NewFormat("first", 0, 8, flatfile.Str),
+	flatfile.NewFormat("last", 8, 8, flatfile.Str),
+	flatfile.NewFormat("balance", 16, 3, flatfile.Num),
+}
+
+// testFmtr ignores the raw line and always returns testFmt.
+func testFmtr(line string) flatfile.LineFmt {
+	return testFmt
+}
+
+// recordingTB captures assertion failures instead of failing a test.
+type recordingTB struct {
+	failures []string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.failures = append(tb.failures, fmt.Sprintf(format, args...))
+}
+
+func (tb *recordingTB) Fatalf(format string, args ...any) {
+	tb.failures = append(tb.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEqualFiles(t *testing.T) {
+	a := Load(t, testFmtr, "alice   anderson100\r\nbob     baker   25 \r\n")
+	b := Load(t, testFmtr, "alice   anderson100\nbob     baker   25 ")
+	AssertEqualFiles(t, a, b)
+
+	if err := b.Set(1, "balance", "999"); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Append("carol   clark   75 ")
+
+	tb := &recordingTB{}
+	AssertEqualFiles(tb, a, b)
+	if len(tb.failures) != 2 {
+		t.Fatalf("expected 2 failures, got %v", tb.failures)
+	}
+
+	if want := `line 1: field "balance": "25" != "999"`; tb.failures[1] != want {
+		t.Fatalf("expected failure %q, got %q", want, tb.failures[1])
+	}
+}
+
+func TestStaticAndRecordingFormatter(t *testing.T) {
+	rec := Record(flatfile.StaticFormatter(testFmt...))
+	ff := flatfile.New(rec.Formatter())
+	ff.Append("alice   anderson100", "bob     baker   25 ")
+
+	if v, _ := ff.Get(1, "last"); v != "baker" {
+		t.Fatalf("expected last baker, got %q", v)
+	}
+
+	lines := rec.Lines()
+	if len(lines) != 2 || lines[0] != "alice   anderson100" {
+		t.Fatalf("unexpected recorded lines %q", lines)
+	}
+}
+
+func TestLoadRejectsShortLines(t *testing.T) {
+	tb := &recordingTB{}
+	Load(tb, testFmtr, "too short")
+	if len(tb.failures) != 1 || !strings.Contains(tb.failures[0], "line 1") {
+		t.Fatalf("expected a line 1 failure, got %v", tb.failures)
+	}
+}
@@ -0,0 +1,395 @@
+package flatfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A JSONType indicates how a field's value is represented when a line is
+// marshaled to JSON.
+type JSONType byte
+
+const (
+	// Str marshals a field's value as a quoted JSON string.
+	Str JSONType = iota
+	// Num marshals a field's value as an unquoted JSON number.
+	Num
+	// Bool marshals a field's value as a JSON boolean.
+	Bool
+	// Object marshals a field's value verbatim as an embedded JSON
+	// object, for hybrid files where a column carries a JSON blob.
+	Object
+	// Array marshals a field's value verbatim as an embedded JSON
+	// array.
+	Array
+	// Timestamp marshals a field's value as an RFC 3339 string
+	// converted from the file's native layout set by WithTimeLayout,
+	// since consumers can't parse a bare 20240131 without context.
+	Timestamp
+)
+
+// String returns the JSON name of a json type.
+func (jt JSONType) String() string {
+	switch jt {
+	case Num:
+		return "number"
+	case Bool:
+		return "boolean"
+	case Object:
+		return "object"
+	case Array:
+		return "array"
+	case Timestamp:
+		return "timestamp"
+	default:
+		return "string"
+	}
+}
+
+// parseJSONType returns the json type named by s, defaulting to Str.
+func parseJSONType(s string) JSONType {
+	switch s {
+	case "number":
+		return Num
+	case "boolean":
+		return Bool
+	case "object":
+		return Object
+	case "array":
+		return Array
+	case "timestamp":
+		return Timestamp
+	default:
+		return Str
+	}
+}
+
+// A Format describes a single field within a line: the key it is
+// retrieved by, the index it begins at, its length in bytes, and how its
+// value is represented in JSON.
+type Format struct {
+	key        string
+	index      int
+	length     int
+	jsonType   JSONType
+	timeLayout string
+	parse      func(raw string) string
+	render     func(value string) string
+	zeroPad    bool
+	sign       SignPosition
+	enum       map[string]string
+	def        string
+	defWrite   bool
+	seq        bool
+	seqStart   int
+	ckOver     []string
+	ckFn       func(values []string) string
+	marshaler  FieldMarshaler
+	ragged     bool
+}
+
+// A SignPosition locates a numeric field's dedicated sign character.
+type SignPosition byte
+
+const (
+	// SignNone declares no dedicated sign character.
+	SignNone SignPosition = iota
+	// SignLeading places the sign in the field's first byte.
+	SignLeading
+	// SignTrailing places the sign in the field's last byte.
+	SignTrailing
+)
+
+// NewFormat returns a format describing a field of the given length
+// beginning at the given index.
+func NewFormat(key string, index, length int, jsonType JSONType) Format {
+	return Format{
+		key:      key,
+		index:    index,
+		length:   length,
+		jsonType: jsonType,
+	}
+}
+
+// ZeroPadded returns a copy of the format that renders right justified
+// and zero padded to the format length, as mainframe-bound numeric
+// fields nearly always require. A blank value renders as all zeros.
+// Parsing strips the leading zeros back off.
+func (f Format) ZeroPadded() Format {
+	f.zeroPad = true
+	return f
+}
+
+// Ragged returns a copy of the format that runs to the end of the line
+// instead of a fixed extent, so a trailing free-text field of varying
+// width parses without the line being rejected for its length. Only a
+// layout's final format should be ragged. The format length becomes a
+// minimum: rendering still pads short values to it, while longer values
+// render in full.
+func (f Format) Ragged() Format {
+	f.ragged = true
+	return f
+}
+
+// fits reports whether a line of the given length holds the field: a
+// ragged format need only be reached, a fixed format held in full.
+func (f Format) fits(lineLen int) bool {
+	if f.ragged {
+		return 0 <= f.index && f.index <= lineLen
+	}
+
+	return 0 <= f.index && 0 <= f.length && f.index+f.length <= lineLen
+}
+
+// extent returns the end of the field's slice of a line of the given
+// length: the fixed extent, or the line end for a ragged format the
+// line reaches.
+func (f Format) extent(lineLen int) int {
+	if f.ragged && f.index <= lineLen {
+		return lineLen
+	}
+
+	return f.index + f.length
+}
+
+// WithChecksum returns a copy of the format whose value is a checksum or
+// hash total computed over the values of the named fields. The value is
+// recomputed whenever the line renders, and a parsed line can be checked
+// against its stored checksum with VerifyChecksums.
+func (f Format) WithChecksum(over []string, compute func(values []string) string) Format {
+	f.ckOver = append([]string(nil), over...)
+	f.ckFn = compute
+	return f
+}
+
+// Sequence returns a copy of the format declaring a record sequence
+// number counted from start. Appending lines to a flat file fills the
+// field automatically, rendered zero padded to the format length, and
+// Resequence renumbers after reordering.
+func (f Format) Sequence(start int) Format {
+	f.seq = true
+	f.seqStart = start
+	f.zeroPad = true
+	return f
+}
+
+// WithDefault returns a copy of the format that substitutes the given
+// default when a parsed value is blank. When emitOnWrite is set the
+// default is rendered back out; otherwise a field still holding the
+// default renders blank, preserving the original file's emptiness.
+func (f Format) WithDefault(def string, emitOnWrite bool) Format {
+	f.def = def
+	f.defWrite = emitOnWrite
+	return f
+}
+
+// WithEnum returns a copy of the format restricted to the given
+// code-to-label set. Setting any other non-blank value is rejected, and
+// a code's label is available through Line.Label. A label may be empty
+// when only validation is wanted.
+func (f Format) WithEnum(values map[string]string) Format {
+	enum := make(map[string]string, len(values))
+	for code, label := range values {
+		enum[code] = label
+	}
+
+	f.enum = enum
+	return f
+}
+
+// checkValue reports whether a value may be stored under the format: it
+// must fit the format length — ragged formats excepted — and, for enum
+// formats, be an allowed code or blank.
+func (f Format) checkValue(value string) error {
+	if f.length < len(value) && !f.ragged {
+		return fmt.Errorf("flatfile: value %q exceeds length %d of field %q", value, f.length, f.key)
+	}
+
+	if f.enum != nil && 0 < len(value) {
+		if _, ok := f.enum[value]; !ok {
+			return fmt.Errorf("flatfile: value %q is not an allowed code for field %q", value, f.key)
+		}
+	}
+
+	return nil
+}
+
+// WithSign returns a copy of the format whose first or last byte is a
+// dedicated sign character. On parse, a '-' there makes the decoded
+// value negative; '+' and space decode as positive. On render, negative
+// values emit '-' and the magnitude, positive values a space.
+func (f Format) WithSign(pos SignPosition) Format {
+	f.sign = pos
+	return f
+}
+
+// A NumberLocale describes the separators a locale writes numbers with.
+type NumberLocale struct {
+	Decimal  rune // separates the integer and fractional parts
+	Grouping rune // separates groups of thousands
+}
+
+var (
+	// NumberUS writes numbers like 1,234.56.
+	NumberUS = NumberLocale{Decimal: '.', Grouping: ','}
+	// NumberEU writes numbers like 1.234,56.
+	NumberEU = NumberLocale{Decimal: ',', Grouping: '.'}
+)
+
+// WithNumberLocale returns a copy of the format that parses numeric
+// values written in the given locale into canonical form — decimal
+// point, no grouping — and renders the decimal separator back on write.
+// Grouping separators are stripped on parse and not re-inserted on
+// render, since re-inserting them would change the field's width.
+func (f Format) WithNumberLocale(loc NumberLocale) Format {
+	f.parse = func(raw string) string {
+		v := strings.ReplaceAll(strings.TrimSpace(raw), string(loc.Grouping), "")
+		return strings.ReplaceAll(v, string(loc.Decimal), ".")
+	}
+	f.render = func(value string) string {
+		return strings.ReplaceAll(value, ".", string(loc.Decimal))
+	}
+	return f
+}
+
+// WithParse returns a copy of the format that extracts values with the
+// given hook instead of default space trimming, so domain-specific
+// cleanup (strip punctuation, uppercase) happens at the parsing
+// boundary. The hook receives the field's raw, untrimmed slice of the
+// line.
+func (f Format) WithParse(parse func(raw string) string) Format {
+	f.parse = parse
+	return f
+}
+
+// WithRender returns a copy of the format that passes values through the
+// given hook before padding when a line is rendered.
+func (f Format) WithRender(render func(value string) string) Format {
+	f.render = render
+	return f
+}
+
+// WithMarshaler returns a copy of the format whose exported value is
+// produced by the given marshaler instead of the json type rules, so
+// callers fully control a field's representation — decoding coded
+// values into labels during export, say.
+func (f Format) WithMarshaler(m FieldMarshaler) Format {
+	f.marshaler = m
+	return f
+}
+
+// WithTimeLayout returns a copy of the format whose values are dates or
+// times written in the given reference layout — 20060102, say — used by
+// the Timestamp json type to convert to and from RFC 3339.
+func (f Format) WithTimeLayout(layout string) Format {
+	f.timeLayout = layout
+	return f
+}
+
+// parseValue extracts a field's value from its raw slice of a line,
+// applying the format's parse hook or default space trimming.
+func (f Format) parseValue(raw string) string {
+	if f.parse != nil {
+		return f.parse(raw)
+	}
+
+	var neg bool
+	switch f.sign {
+	case SignLeading:
+		if 0 < len(raw) {
+			neg = raw[0] == '-'
+			raw = raw[1:]
+		}
+	case SignTrailing:
+		if 0 < len(raw) {
+			neg = raw[len(raw)-1] == '-'
+			raw = raw[:len(raw)-1]
+		}
+	}
+
+	v := strings.TrimSpace(raw)
+	if f.zeroPad {
+		if trimmed := strings.TrimLeft(v, "0"); 0 < len(trimmed) {
+			v = trimmed
+		} else if 0 < len(v) {
+			v = "0"
+		}
+	}
+
+	if neg && 0 < len(v) {
+		v = "-" + v
+	}
+
+	if len(v) == 0 && 0 < len(f.def) {
+		v = f.def
+	}
+
+	return v
+}
+
+// Key returns the key a field is retrieved by.
+func (f Format) Key() string {
+	return f.key
+}
+
+// Index returns the index a field begins at within a line.
+func (f Format) Index() int {
+	return f.index
+}
+
+// Length returns the length of a field in bytes.
+func (f Format) Length() int {
+	return f.length
+}
+
+// JSONType returns how a field's value is represented in JSON.
+func (f Format) JSONType() JSONType {
+	return f.jsonType
+}
+
+// MarshalJSON returns a format as a JSON object.
+func (f Format) MarshalJSON() ([]byte, error) {
+	s := "{\"key\":" + strconv.Quote(f.key) +
+		",\"index\":" + strconv.Itoa(f.index) +
+		",\"length\":" + strconv.Itoa(f.length) +
+		",\"jsonType\":" + strconv.Quote(f.jsonType.String()) + "}"
+	return []byte(s), nil
+}
+
+// UnmarshalJSON parses a format from a JSON object.
+func (f *Format) UnmarshalJSON(b []byte) error {
+	var aux struct {
+		Key      string `json:"key"`
+		Index    int    `json:"index"`
+		Length   int    `json:"length"`
+		JSONType string `json:"jsonType"`
+	}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	f.key = aux.Key
+	f.index = aux.Index
+	f.length = aux.Length
+	f.jsonType = parseJSONType(aux.JSONType)
+	return nil
+}
+
+// A LineFmt is an ordered set of formats describing every field in a
+// line.
+type LineFmt []Format
+
+// A Formatter returns the line format used to parse a given raw line.
+// Flat files with a single record layout may ignore the line and return
+// a fixed format.
+type Formatter func(line string) LineFmt
+
+// StaticFormatter returns a formatter assigning every line the same
+// layout, the common case for files without record-type discriminators.
+func StaticFormatter(fmts ...Format) Formatter {
+	lf := LineFmt(fmts)
+	return func(string) LineFmt { return lf }
+}
@@ -0,0 +1,160 @@
+package flatfile
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// Generate renders a Go source file exposing a layout as a typed struct
+// named name in package pkg, with constants for each field's offset and
+// length and Parse/Render functions, eliminating stringly-typed key
+// access in application code. Str fields become string, Num float64,
+// and Bool bool. The output is gofmt-formatted and carries the standard
+// generated-code header.
+func Generate(pkg, name string, layout []Format) ([]byte, error) {
+	if len(layout) == 0 {
+		return nil, fmt.Errorf("flatfile: cannot generate %s from an empty layout", name)
+	}
+
+	var (
+		sb    strings.Builder
+		width int
+	)
+
+	for _, f := range layout {
+		if width < f.index+f.length {
+			width = f.index + f.length
+		}
+	}
+
+	typed := false
+	for _, f := range layout {
+		if f.jsonType == Num || f.jsonType == Bool {
+			typed = true
+		}
+	}
+
+	fmt.Fprintf(&sb, "// Code generated by flatfile. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if typed {
+		sb.WriteString("import (\n\t\"fmt\"\n\t\"strconv\"\n\t\"strings\"\n)\n\n")
+	} else {
+		sb.WriteString("import (\n\t\"fmt\"\n\t\"strings\"\n)\n\n")
+	}
+
+	fmt.Fprintf(&sb, "// %s field offsets and lengths.\nconst (\n", name)
+	for _, f := range layout {
+		fmt.Fprintf(&sb, "\t%s%sIndex = %d\n", name, goName(f.key), f.index)
+		fmt.Fprintf(&sb, "\t%s%sLength = %d\n", name, goName(f.key), f.length)
+	}
+
+	fmt.Fprintf(&sb, "\n\t%sWidth = %d\n)\n\n", name, width)
+
+	fmt.Fprintf(&sb, "// A %s is one parsed line.\ntype %s struct {\n", name, name)
+	for _, f := range layout {
+		fmt.Fprintf(&sb, "\t%s %s\n", goName(f.key), goType(f.jsonType))
+	}
+
+	sb.WriteString("}\n\n")
+
+	fmt.Fprintf(&sb, "// Parse%s parses a raw line of at least %sWidth bytes.\n", name, name)
+	fmt.Fprintf(&sb, "func Parse%s(line string) (%s, error) {\n", name, name)
+	fmt.Fprintf(&sb, "\tvar v %s\n", name)
+	fmt.Fprintf(&sb, "\tif len(line) < %sWidth {\n", name)
+	fmt.Fprintf(&sb, "\t\treturn v, fmt.Errorf(\"%s: line of length %%d is too short\", len(line))\n\t}\n\n", name)
+	for _, f := range layout {
+		raw := fmt.Sprintf("strings.TrimSpace(line[%s%sIndex : %s%sIndex+%s%sLength])", name, goName(f.key), name, goName(f.key), name, goName(f.key))
+		switch f.jsonType {
+		case Num:
+			fmt.Fprintf(&sb, "\tif raw := %s; 0 < len(raw) {\n", raw)
+			fmt.Fprintf(&sb, "\t\tn, err := strconv.ParseFloat(raw, 64)\n\t\tif err != nil {\n")
+			fmt.Fprintf(&sb, "\t\t\treturn v, fmt.Errorf(\"%s: field %s: %%w\", err)\n\t\t}\n\n", name, f.key)
+			fmt.Fprintf(&sb, "\t\tv.%s = n\n\t}\n\n", goName(f.key))
+		case Bool:
+			fmt.Fprintf(&sb, "\tif raw := %s; 0 < len(raw) {\n", raw)
+			fmt.Fprintf(&sb, "\t\tb, err := strconv.ParseBool(strings.ToLower(raw))\n\t\tif err != nil {\n")
+			fmt.Fprintf(&sb, "\t\t\treturn v, fmt.Errorf(\"%s: field %s: %%w\", err)\n\t\t}\n\n", name, f.key)
+			fmt.Fprintf(&sb, "\t\tv.%s = b\n\t}\n\n", goName(f.key))
+		default:
+			fmt.Fprintf(&sb, "\tv.%s = %s\n", goName(f.key), raw)
+		}
+	}
+
+	sb.WriteString("\treturn v, nil\n}\n\n")
+
+	fmt.Fprintf(&sb, "// Render renders the line padded to %sWidth bytes.\n", name)
+	fmt.Fprintf(&sb, "// It errors if a value does not fit its field.\n")
+	fmt.Fprintf(&sb, "func (v %s) Render() (string, error) {\n", name)
+	fmt.Fprintf(&sb, "\tb := []byte(strings.Repeat(\" \", %sWidth))\n", name)
+	for _, f := range layout {
+		sb.WriteString("\t{\n")
+		switch f.jsonType {
+		case Num:
+			fmt.Fprintf(&sb, "\t\ts := strconv.FormatFloat(v.%s, 'f', -1, 64)\n", goName(f.key))
+		case Bool:
+			fmt.Fprintf(&sb, "\t\ts := strconv.FormatBool(v.%s)\n", goName(f.key))
+		default:
+			fmt.Fprintf(&sb, "\t\ts := v.%s\n", goName(f.key))
+		}
+
+		fmt.Fprintf(&sb, "\t\tif %s%sLength < len(s) {\n", name, goName(f.key))
+		fmt.Fprintf(&sb, "\t\t\treturn \"\", fmt.Errorf(\"%s: value %%q overflows field %s\", s)\n\t\t}\n\n", name, f.key)
+		fmt.Fprintf(&sb, "\t\tcopy(b[%s%sIndex:], s)\n\t}\n\n", name, goName(f.key))
+	}
+
+	sb.WriteString("\treturn string(b), nil\n}\n")
+
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return nil, fmt.Errorf("flatfile: generated invalid source: %w", err)
+	}
+
+	return src, nil
+}
+
+// goType maps a json type to the generated struct field type.
+func goType(t JSONType) string {
+	switch t {
+	case Num:
+		return "float64"
+	case Bool:
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+// goName converts a field key to an exported Go identifier, capitalizing
+// after separators and dropping characters identifiers cannot hold.
+func goName(key string) string {
+	var (
+		sb    strings.Builder
+		upper = true
+	)
+
+	for _, r := range key {
+		switch {
+		case 'a' <= r && r <= 'z':
+			if upper {
+				r -= 'a' - 'A'
+			}
+
+			sb.WriteRune(r)
+			upper = false
+		case 'A' <= r && r <= 'Z':
+			sb.WriteRune(r)
+			upper = false
+		case '0' <= r && r <= '9':
+			sb.WriteRune(r)
+			upper = true
+		default:
+			upper = true
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "Field"
+	}
+
+	return sb.String()
+}
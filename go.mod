@@ -0,0 +1,3 @@
+module github.com/nathangreene3/flatfile
+
+go 1.21
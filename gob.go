@@ -0,0 +1,185 @@
+package flatfile
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Gob support. Every field in this package is unexported, so plain gob
+// would encode nothing; each type instead round-trips through an
+// exported wire struct. Hook functions — parse and render hooks,
+// checksum functions, formatters, and trailer fills — cannot be
+// serialized and are dropped; reattach them after decoding if needed.
+
+// gobFormat mirrors Format's encodable state.
+type gobFormat struct {
+	Key        string
+	Index      int
+	Length     int
+	JSONType   JSONType
+	TimeLayout string
+	ZeroPad    bool
+	Sign       SignPosition
+	Enum       map[string]string
+	Def        string
+	DefWrite   bool
+	Seq        bool
+	SeqStart   int
+	CkOver     []string
+	Ragged     bool
+}
+
+// GobEncode implements gob.GobEncoder.
+func (f Format) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(gobFormat{
+		Key:        f.key,
+		Index:      f.index,
+		Length:     f.length,
+		JSONType:   f.jsonType,
+		TimeLayout: f.timeLayout,
+		ZeroPad:    f.zeroPad,
+		Sign:       f.sign,
+		Enum:       f.enum,
+		Def:        f.def,
+		DefWrite:   f.defWrite,
+		Seq:        f.seq,
+		SeqStart:   f.seqStart,
+		CkOver:     f.ckOver,
+		Ragged:     f.ragged,
+	})
+
+	return buf.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder.
+func (f *Format) GobDecode(b []byte) error {
+	var w gobFormat
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+
+	*f = Format{
+		key:        w.Key,
+		index:      w.Index,
+		length:     w.Length,
+		jsonType:   w.JSONType,
+		timeLayout: w.TimeLayout,
+		zeroPad:    w.ZeroPad,
+		sign:       w.Sign,
+		enum:       w.Enum,
+		def:        w.Def,
+		defWrite:   w.DefWrite,
+		seq:        w.Seq,
+		seqStart:   w.SeqStart,
+		ckOver:     w.CkOver,
+		ragged:     w.Ragged,
+	}
+
+	return nil
+}
+
+// gobField mirrors Field's encodable state.
+type gobField struct {
+	Format Format
+	Value  string
+}
+
+// GobEncode implements gob.GobEncoder.
+func (f Field) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(gobField{Format: f.Format, Value: f.value})
+	return buf.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder.
+func (f *Field) GobDecode(b []byte) error {
+	var w gobField
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+
+	*f = Field{Format: w.Format, value: w.Value}
+	return nil
+}
+
+// gobLine mirrors Line's encodable state; the key index is rebuilt on
+// decode.
+type gobLine struct {
+	Fields       []Field
+	Length       int
+	Checksum     string
+	ChecksumAlgo ChecksumAlgo
+	SrcName      string
+	SrcLine      int
+	Tags         map[string]string
+	WidthMode    WidthMode
+}
+
+// GobEncode implements gob.GobEncoder.
+func (ln *Line) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(gobLine{
+		Fields:       ln.fields,
+		Length:       ln.length,
+		Checksum:     ln.checksum,
+		ChecksumAlgo: ln.checksumAlgo,
+		SrcName:      ln.srcName,
+		SrcLine:      ln.srcLine,
+		Tags:         ln.tags,
+		WidthMode:    ln.widthMode,
+	})
+
+	return buf.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder.
+func (ln *Line) GobDecode(b []byte) error {
+	var w gobLine
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+
+	ln.fields = w.Fields
+	ln.keyToIndex = make(map[string]int, len(w.Fields))
+	ln.length = w.Length
+	ln.checksum = w.Checksum
+	ln.checksumAlgo = w.ChecksumAlgo
+	ln.srcName = w.SrcName
+	ln.srcLine = w.SrcLine
+	ln.tags = w.Tags
+	ln.widthMode = w.WidthMode
+	for i, f := range w.Fields {
+		ln.keyToIndex[f.key] = i
+	}
+
+	return nil
+}
+
+// gobFlatFile mirrors FlatFile's encodable state.
+type gobFlatFile struct {
+	Lines []*Line
+	Meta  map[string]string
+}
+
+// GobEncode implements gob.GobEncoder.
+func (ff *FlatFile) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(gobFlatFile{Lines: ff.lines, Meta: ff.meta})
+	return buf.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder, replacing any existing lines. The
+// decoded flat file has no formatter, so attach one with New before
+// appending raw lines to it.
+func (ff *FlatFile) GobDecode(b []byte) error {
+	var w gobFlatFile
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&w); err != nil {
+		return err
+	}
+
+	ff.lines = w.Lines
+	ff.meta = w.Meta
+	ff.shared = false
+	return nil
+}
@@ -0,0 +1,112 @@
+package flatfile
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Handler returns an http.Handler exposing a flat file as JSON, so
+// internal tools can browse loaded feeds without bespoke glue:
+//
+//	GET /lines?offset=0&limit=100   a page of lines
+//	GET /lines?key=<k>&value=<v>    the lines whose field k holds v
+//	GET /lines/<i>                  a single line by index
+//
+// Each line is rendered as an object of field keys to values. Paging and
+// filtering combine; limit defaults to 100. The handler only reads, so
+// it is safe to serve while the program also reads the flat file, but
+// not while it mutates it.
+func (ff *FlatFile) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lines", ff.serveLines)
+	mux.HandleFunc("/lines/", ff.serveLine)
+	return mux
+}
+
+// serveLines lists lines as JSON, optionally filtered by a field value
+// and paged with offset and limit.
+func (ff *FlatFile) serveLines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	lns := ff.lines
+	if key := q.Get("key"); 0 < len(key) {
+		lns = ff.LinesWhere(key, q.Get("value"))
+	}
+
+	offset, err := formValue(q.Get("offset"), 0)
+	if err != nil {
+		http.Error(w, "invalid offset", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := formValue(q.Get("limit"), 100)
+	if err != nil {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	if len(lns) < offset {
+		offset = len(lns)
+	}
+
+	if len(lns) < offset+limit {
+		limit = len(lns) - offset
+	}
+
+	page := make([]map[string]string, 0, limit)
+	for _, ln := range lns[offset : offset+limit] {
+		page = append(page, ln.KeyValues())
+	}
+
+	writeJSON(w, page)
+}
+
+// serveLine returns the line at the index named by the path.
+func (ff *FlatFile) serveLine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	i, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/lines/"))
+	if err != nil {
+		http.Error(w, "invalid line index", http.StatusBadRequest)
+		return
+	}
+
+	if i < 0 || len(ff.lines) <= i {
+		http.Error(w, "no such line", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, ff.lines[i].KeyValues())
+}
+
+// formValue parses a non-negative integer query parameter, substituting
+// a default when it is absent.
+func formValue(s string, def int) (int, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, strconv.ErrSyntax
+	}
+
+	return n, nil
+}
+
+// writeJSON encodes v to the response with a JSON content type.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
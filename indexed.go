@@ -0,0 +1,180 @@
+package flatfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// indexVersion is the sidecar index layout version. OpenIndexed rejects
+// versions it does not know, so the layout can evolve without silently
+// misreading stale indexes.
+const indexVersion = 1
+
+// WriteIndex scans the flat file at path and writes a sidecar index of
+// its line byte offsets to idxPath, so the file can later be opened
+// with OpenIndexed and read randomly without loading it whole. Empty
+// lines are skipped, matching ReadFile.
+func WriteIndex(path, idxPath string) error {
+	offsets, err := scanOffsets(path)
+	if err != nil {
+		return err
+	}
+
+	idx, err := os.Create(idxPath)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+
+	bw := bufio.NewWriter(idx)
+	bw.WriteByte(indexVersion)
+	writeUvarint(bw, uint64(len(offsets)))
+	for _, off := range offsets {
+		writeUvarint(bw, uint64(off[0]))
+		writeUvarint(bw, uint64(off[1]))
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	return idx.Close()
+}
+
+// scanOffsets returns the byte offset and length of each non-empty line
+// of the file at path.
+func scanOffsets(path string) ([][2]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		offset  int64
+		offsets [][2]int64
+		sc      = bufio.NewScanner(file)
+	)
+
+	for sc.Scan() {
+		n := int64(len(sc.Bytes()))
+		if 0 < n {
+			offsets = append(offsets, [2]int64{offset, n})
+		}
+
+		offset += n + 1
+	}
+
+	return offsets, sc.Err()
+}
+
+// An IndexedFile reads single lines of a flat file on disk through a
+// sidecar offset index, giving O(1) access to any line of a file too
+// large to load.
+type IndexedFile struct {
+	file    *os.File
+	fmtr    Formatter
+	offsets [][2]int64
+	cache   *lineCache
+}
+
+// OpenIndexed opens the flat file at path alongside the sidecar index
+// at idxPath written by WriteIndex. The caller must Close the result.
+func OpenIndexed(fmtr Formatter, path, idxPath string) (*IndexedFile, error) {
+	idx, err := os.Open(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	defer idx.Close()
+
+	br := bufio.NewReader(idx)
+	version, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	if version != indexVersion {
+		return nil, fmt.Errorf("flatfile: unsupported index version %d", version)
+	}
+
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([][2]int64, 0, n)
+	for ; 0 < n; n-- {
+		off, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+
+		length, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+
+		offsets = append(offsets, [2]int64{int64(off), int64(length)})
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexedFile{file: file, fmtr: fmtr, offsets: offsets}, nil
+}
+
+// WithCache keeps the most recently read capacity lines parsed in
+// memory, so hot records skip the disk read and reparse while memory
+// stays bounded. A capacity under one disables caching.
+func (f *IndexedFile) WithCache(capacity int) *IndexedFile {
+	if capacity < 1 {
+		f.cache = nil
+	} else {
+		f.cache = newLineCache(capacity)
+	}
+
+	return f
+}
+
+// Len returns the number of indexed lines.
+func (f *IndexedFile) Len() int {
+	return len(f.offsets)
+}
+
+// Line reads and parses the ith line.
+func (f *IndexedFile) Line(i int) (*Line, error) {
+	if i < 0 || len(f.offsets) <= i {
+		return nil, fmt.Errorf("flatfile: index %d out of range [0, %d)", i, len(f.offsets))
+	}
+
+	if f.cache != nil {
+		if ln, ok := f.cache.get(i); ok {
+			return ln.Copy(), nil
+		}
+	}
+
+	b := make([]byte, f.offsets[i][1])
+	if _, err := f.file.ReadAt(b, f.offsets[i][0]); err != nil {
+		return nil, err
+	}
+
+	ln, err := ParseSafe(f.fmtr, string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	if f.cache != nil {
+		f.cache.put(i, ln.Copy())
+	}
+
+	return ln, nil
+}
+
+// Close closes the underlying file.
+func (f *IndexedFile) Close() error {
+	return f.file.Close()
+}
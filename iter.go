@@ -0,0 +1,32 @@
+package flatfile
+
+// A Seq2 is an iterator over pairs of values. It has the same shape as
+// iter.Seq2, so on Go 1.23 and later it may be ranged over directly:
+//
+//	for i, ln := range ff.Lines() { ... }
+//
+// On earlier versions it may be invoked with an explicit yield function.
+type Seq2[K, V any] func(yield func(K, V) bool)
+
+// Lines returns an iterator over copies of a flat file's lines and
+// their indices.
+func (ff *FlatFile) Lines() Seq2[int, *Line] {
+	return func(yield func(int, *Line) bool) {
+		for i, ln := range ff.lines {
+			if !yield(i, ln.Copy()) {
+				return
+			}
+		}
+	}
+}
+
+// Fields returns an iterator over a line's fields and their indices.
+func (ln *Line) Fields() Seq2[int, Field] {
+	return func(yield func(int, Field) bool) {
+		for i, f := range ln.fields {
+			if !yield(i, f) {
+				return
+			}
+		}
+	}
+}
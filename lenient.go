@@ -0,0 +1,99 @@
+package flatfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// loadSamples caps how many skipped lines a LoadReport retains verbatim.
+const loadSamples = 10
+
+// A SkippedLine records one line a lenient load rejected.
+type SkippedLine struct {
+	Line int    // 1-based line number
+	Text string // the raw line
+	Err  error
+}
+
+// A LoadReport summarizes a lenient load.
+type LoadReport struct {
+	Read    int           // non-empty lines read
+	Loaded  int           // lines loaded
+	Skipped int           // lines skipped
+	Samples []SkippedLine // the first loadSamples skipped lines
+}
+
+// WithReject configures a writer that lenient loads copy rejected raw
+// lines to, each with the rejection reason appended as a final column,
+// matching the reject-file convention of mainstream ETL tools. It
+// returns the receiver for chaining.
+func (ff *FlatFile) WithReject(w io.Writer) *FlatFile {
+	ff.reject = w
+	return ff
+}
+
+// ReadFromLenient reads and appends each non-empty line from a reader,
+// skipping lines the formatter cannot parse instead of aborting the
+// load, so one bad record does not sink a nightly file. The report
+// carries counts and the first few rejected lines with their errors;
+// the returned error reports reader and reject-writer failures only.
+// With a reject writer configured, every rejected line is also written
+// there as "<line> | line <n>: <reason>".
+func (ff *FlatFile) ReadFromLenient(r io.Reader) (LoadReport, error) {
+	return ff.readFromLenient(r, "")
+}
+
+// readFromLenient reads and appends each non-empty parsable line from a
+// reader, stamping each loaded line with the given source name and its
+// line number.
+func (ff *FlatFile) readFromLenient(r io.Reader, name string) (LoadReport, error) {
+	var (
+		report LoadReport
+		lineNo int
+		sc     = bufio.NewScanner(r)
+	)
+
+	for sc.Scan() {
+		lineNo++
+		line := sc.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		report.Read++
+		ln, err := ParseSafe(ff.fmtr, expandTabs(line, ff.tabWidth))
+		if err != nil {
+			report.Skipped++
+			if len(report.Samples) < loadSamples {
+				report.Samples = append(report.Samples, SkippedLine{Line: lineNo, Text: line, Err: err})
+			}
+
+			if ff.reject != nil {
+				if _, err := fmt.Fprintf(ff.reject, "%s | line %d: %v\n", line, lineNo, err); err != nil {
+					return report, err
+				}
+			}
+
+			continue
+		}
+
+		ln.srcName, ln.srcLine = name, lineNo
+		ff.AppendLines(ln)
+		report.Loaded++
+	}
+
+	return report, sc.Err()
+}
+
+// ReadFileLenient reads a file with ReadFromLenient.
+func (ff *FlatFile) ReadFileLenient(path string) (LoadReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return LoadReport{}, err
+	}
+	defer file.Close()
+
+	return ff.readFromLenient(file, path)
+}
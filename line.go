@@ -0,0 +1,581 @@
+package flatfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Line is an ordered collection of fields parsed from a single line of
+// a flat file.
+type Line struct {
+	fields       []Field
+	keyToIndex   map[string]int
+	length       int
+	checksum     string
+	checksumAlgo ChecksumAlgo
+	dirty        bool
+	srcName      string
+	srcLine      int
+	tags         map[string]string
+	widthMode    WidthMode
+}
+
+// A WidthMode selects the width Line.String pads to.
+type WidthMode byte
+
+const (
+	// WidthCaptured pads to the length of the line as originally
+	// captured, preserving byte-for-byte round trips of unmodified
+	// files.
+	WidthCaptured WidthMode = iota
+	// WidthExtents pads only to the furthest format extent, shedding
+	// the stale trailing length a modified line otherwise keeps when
+	// its new values render shorter than the original line.
+	WidthExtents
+)
+
+// A DuplicateKeyError reports a layout assigning the same key to more
+// than one field, which would otherwise let the later field silently
+// shadow the earlier one.
+type DuplicateKeyError struct {
+	Key string
+}
+
+// Error implements error.
+func (e DuplicateKeyError) Error() string {
+	return fmt.Sprintf("flatfile: duplicate format key %q", e.Key)
+}
+
+// NewLine parses a raw line into fields under the given line format.
+// Each format's extent must lie within the line, and each key must be
+// unique; a duplicate key panics with a DuplicateKeyError, catching
+// broken formatters immediately.
+func NewLine(lf LineFmt, line string) *Line {
+	ln := &Line{
+		fields:     make([]Field, 0, len(lf)),
+		keyToIndex: make(map[string]int, len(lf)),
+		length:     len(line),
+	}
+
+	for _, f := range lf {
+		if _, ok := ln.keyToIndex[f.key]; ok {
+			panic(DuplicateKeyError{Key: f.key})
+		}
+
+		ln.keyToIndex[f.key] = len(ln.fields)
+		ln.fields = append(ln.fields, NewField(f, f.parseValue(line[f.index:f.extent(len(line))])))
+	}
+
+	return ln
+}
+
+// NewLineSafe is NewLine returning errors instead of panicking: a
+// format overrunning the line, or a duplicate key, comes back as an
+// error naming the offending format, for callers parsing input they do
+// not control.
+func NewLineSafe(lf LineFmt, line string) (*Line, error) {
+	seen := make(map[string]bool, len(lf))
+	for _, f := range lf {
+		if seen[f.key] {
+			return nil, DuplicateKeyError{Key: f.key}
+		}
+
+		seen[f.key] = true
+		if !f.fits(len(line)) {
+			if f.ragged {
+				return nil, fmt.Errorf("flatfile: line of length %d does not reach ragged field %q at %d", len(line), f.key, f.index)
+			}
+
+			return nil, fmt.Errorf("flatfile: line of length %d does not hold field %q at [%d:%d]", len(line), f.key, f.index, f.index+f.length)
+		}
+	}
+
+	return NewLine(lf, line), nil
+}
+
+// BuildLine constructs a line programmatically from formats and a map of
+// values, without assembling and re-parsing a padded string. Formats
+// without a value entry are left blank. It errors on duplicate format
+// keys, on value keys naming no format, and on values longer than their
+// format.
+func BuildLine(fmts []Format, values map[string]string) (*Line, error) {
+	fields := make([]Field, 0, len(fmts))
+	seen := make(map[string]bool, len(fmts))
+	for _, f := range fmts {
+		if seen[f.key] {
+			return nil, DuplicateKeyError{Key: f.key}
+		}
+
+		seen[f.key] = true
+		v := values[f.key]
+		if err := f.checkValue(v); err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, NewField(f, v))
+	}
+
+	for key := range values {
+		if !seen[key] {
+			return nil, fmt.Errorf("flatfile: no format with key %q", key)
+		}
+	}
+
+	return newLineFromFields(fields), nil
+}
+
+// NewLineFromMap builds a line under the given line format from a map of
+// values, the inverse of KeyValues, making map-based round trips one
+// call each way. Map keys naming no format are ignored, so over-full
+// input such as an HTTP form posts cleanly. It errors on duplicate
+// format keys and on values longer than their format.
+func NewLineFromMap(lf LineFmt, m map[string]string) (*Line, error) {
+	known := make(map[string]string, len(lf))
+	for _, f := range lf {
+		if v, ok := m[f.key]; ok {
+			known[f.key] = v
+		}
+	}
+
+	return BuildLine(lf, known)
+}
+
+// newLineFromFields builds a line directly from fields, deriving its
+// rendered length from the furthest field extent.
+func newLineFromFields(fields []Field) *Line {
+	ln := &Line{
+		fields:     fields,
+		keyToIndex: make(map[string]int, len(fields)),
+	}
+
+	for i, f := range fields {
+		ln.keyToIndex[f.key] = i
+		if ln.length < f.index+f.length {
+			ln.length = f.index + f.length
+		}
+	}
+
+	return ln
+}
+
+// fillSequences sets every sequence field to its start plus the line's
+// position in the file.
+func (ln *Line) fillSequences(position int) {
+	for i, f := range ln.fields {
+		if f.seq {
+			ln.fields[i].value = strconv.Itoa(f.seqStart + position)
+		}
+	}
+}
+
+// Get returns the value of the field having the given key.
+func (ln *Line) Get(key string) (string, error) {
+	i, ok := ln.keyToIndex[key]
+	if !ok {
+		return "", fmt.Errorf("flatfile: no field with key %q", key)
+	}
+
+	return ln.fields[i].value, nil
+}
+
+// Set updates the value of the field having the given key. The value
+// must fit within the field's format length.
+func (ln *Line) Set(key, value string) error {
+	i, ok := ln.keyToIndex[key]
+	if !ok {
+		return fmt.Errorf("flatfile: no field with key %q", key)
+	}
+
+	if err := ln.fields[i].checkValue(value); err != nil {
+		return err
+	}
+
+	ln.fields[i].value = value
+	ln.dirty = true
+	return nil
+}
+
+// Label returns the enum label decoded from the value of the field
+// having the given key. Fields without an enum, and codes without a
+// label, return the value itself.
+func (ln *Line) Label(key string) (string, error) {
+	i, ok := ln.keyToIndex[key]
+	if !ok {
+		return "", fmt.Errorf("flatfile: no field with key %q", key)
+	}
+
+	f := ln.fields[i]
+	if label, ok := f.enum[f.value]; ok && 0 < len(label) {
+		return label, nil
+	}
+
+	return f.value, nil
+}
+
+// SetRaw replaces a line's content by re-parsing the given raw text
+// against the line's existing formats. It errors without modifying the
+// line if the text is too short for any format; a ragged format need
+// only be reached and takes the rest of the text, as when parsing.
+func (ln *Line) SetRaw(s string) error {
+	for _, f := range ln.fields {
+		if !f.fits(len(s)) {
+			return fmt.Errorf("flatfile: text of length %d is too short for field %q", len(s), f.key)
+		}
+	}
+
+	for i, f := range ln.fields {
+		ln.fields[i].value = f.parseValue(s[f.index:f.extent(len(s))])
+	}
+
+	ln.length = len(s)
+	ln.dirty = true
+	return nil
+}
+
+// Source returns where a line was read from: the source name — the path
+// for lines read by ReadFile, empty for lines built in memory — and the
+// 1-based line number within it, zero for lines built in memory. It
+// lets validation errors point at the exact original input location.
+func (ln *Line) Source() (name string, line int) {
+	return ln.srcName, ln.srcLine
+}
+
+// SetTag attaches an annotation to a line. Tags never appear in the
+// rendered flat output, but travel with the line in memory and in JSON,
+// useful for carrying processing state such as matched, suspect, or a
+// source batch.
+func (ln *Line) SetTag(key, value string) {
+	if ln.tags == nil {
+		ln.tags = make(map[string]string)
+	}
+
+	ln.tags[key] = value
+}
+
+// Tag returns the annotation with the given key, and whether it is set.
+func (ln *Line) Tag(key string) (string, bool) {
+	value, ok := ln.tags[key]
+	return value, ok
+}
+
+// SetWidthMode selects the width the line pads to when rendered.
+func (ln *Line) SetWidthMode(m WidthMode) {
+	ln.widthMode = m
+}
+
+// Keys returns each field's key in field order.
+func (ln *Line) Keys() []string {
+	keys := make([]string, 0, len(ln.fields))
+	for _, f := range ln.fields {
+		keys = append(keys, f.key)
+	}
+
+	return keys
+}
+
+// Values returns each field's value in field order.
+func (ln *Line) Values() []string {
+	values := make([]string, 0, len(ln.fields))
+	for _, f := range ln.fields {
+		values = append(values, f.value)
+	}
+
+	return values
+}
+
+// KeyValues returns a map of each field's key to its value.
+func (ln *Line) KeyValues() map[string]string {
+	kvs := make(map[string]string, len(ln.fields))
+	for _, f := range ln.fields {
+		kvs[f.key] = f.value
+	}
+
+	return kvs
+}
+
+// Formats returns a copy of each field's format in field order.
+func (ln *Line) Formats() LineFmt {
+	lf := make(LineFmt, 0, len(ln.fields))
+	for _, f := range ln.fields {
+		lf = append(lf, f.Format)
+	}
+
+	return lf
+}
+
+// Len returns the number of fields in a line.
+func (ln *Line) Len() int {
+	return len(ln.fields)
+}
+
+// ByteLen returns the length of a line's string representation.
+func (ln *Line) ByteLen() int {
+	return ln.length
+}
+
+// String returns a line rendered as it would appear in a flat file, with
+// each field's value padded to its format length.
+func (ln *Line) String() string {
+	var length int
+	if ln.widthMode == WidthCaptured {
+		length = ln.length
+	}
+
+	for _, f := range ln.fields {
+		if end := f.index + f.renderedLength(); length < end {
+			length = end
+		}
+	}
+
+	b := []byte(strings.Repeat(" ", length))
+	for _, f := range ln.fields {
+		if f.ckFn != nil {
+			f.value = ln.checksumValue(f)
+		}
+
+		copy(b[f.index:f.index+f.renderedLength()], f.String())
+	}
+
+	return string(b)
+}
+
+// renderedLength returns the width a field renders at: the format
+// length, except that a ragged field holding a longer value renders in
+// full.
+func (f Field) renderedLength() int {
+	if f.ragged && f.length < len(f.String()) {
+		return len(f.String())
+	}
+
+	return f.length
+}
+
+// checksumValue recomputes a checksum field's value from the fields it
+// covers.
+func (ln *Line) checksumValue(f Field) string {
+	values := make([]string, 0, len(f.ckOver))
+	for _, key := range f.ckOver {
+		v, _ := ln.Get(key)
+		values = append(values, v)
+	}
+
+	return f.ckFn(values)
+}
+
+// VerifyChecksums confirms that every checksum field's parsed value
+// matches the value recomputed from the fields it covers, detecting
+// records corrupted in transit.
+func (ln *Line) VerifyChecksums() error {
+	for _, f := range ln.fields {
+		if f.ckFn == nil {
+			continue
+		}
+
+		if want := ln.checksumValue(f); f.value != want {
+			return fmt.Errorf("flatfile: checksum field %q holds %q, computed %q", f.key, f.value, want)
+		}
+	}
+
+	return nil
+}
+
+// SetValues applies several field updates at once with all-or-nothing
+// semantics: every key must exist and every value must fit its field, or
+// no field is modified.
+func (ln *Line) SetValues(m map[string]string) error {
+	for key, value := range m {
+		i, ok := ln.keyToIndex[key]
+		if !ok {
+			return fmt.Errorf("flatfile: no field with key %q", key)
+		}
+
+		if err := ln.fields[i].checkValue(value); err != nil {
+			return err
+		}
+	}
+
+	for key, value := range m {
+		ln.fields[ln.keyToIndex[key]].value = value
+	}
+
+	if 0 < len(m) {
+		ln.dirty = true
+	}
+
+	return nil
+}
+
+// Merge copies the other line's non-blank values into the fields the
+// receiver shares keys with, so a partial update record can be applied
+// onto a full one. Unless overwrite is set, fields the receiver already
+// holds a value for are left alone. It errors if a copied value does not
+// fit the receiver's field.
+func (ln *Line) Merge(other *Line, overwrite bool) error {
+	for _, f := range other.fields {
+		if len(f.value) == 0 {
+			continue
+		}
+
+		i, ok := ln.keyToIndex[f.key]
+		if !ok {
+			continue
+		}
+
+		if !overwrite && 0 < len(ln.fields[i].value) {
+			continue
+		}
+
+		if ln.fields[i].length < len(f.value) {
+			return fmt.Errorf("flatfile: value %q exceeds length %d of field %q", f.value, ln.fields[i].length, f.key)
+		}
+
+		ln.fields[i].value = f.value
+		ln.dirty = true
+	}
+
+	return nil
+}
+
+// RenameKey changes the key a field is retrieved by, leaving its value
+// and position untouched. It errors if no field has the old key or a
+// field already has the new one.
+func (ln *Line) RenameKey(old, new string) error {
+	i, ok := ln.keyToIndex[old]
+	if !ok {
+		return fmt.Errorf("flatfile: no field with key %q", old)
+	}
+
+	if _, ok := ln.keyToIndex[new]; ok {
+		return fmt.Errorf("flatfile: field with key %q already exists", new)
+	}
+
+	ln.fields[i].key = new
+	delete(ln.keyToIndex, old)
+	ln.keyToIndex[new] = i
+	return nil
+}
+
+// Equal reports whether two lines hold the same fields — keys, values,
+// indices, lengths, and json types — in the same order.
+func (ln *Line) Equal(other *Line) bool {
+	if len(ln.fields) != len(other.fields) {
+		return false
+	}
+
+	for i, f := range ln.fields {
+		o := other.fields[i]
+		if f.key != o.key || f.value != o.value || f.index != o.index || f.length != o.length || f.jsonType != o.jsonType {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Copy returns a deep copy of a line.
+func (ln *Line) Copy() *Line {
+	cpy := &Line{
+		fields:       make([]Field, len(ln.fields)),
+		keyToIndex:   make(map[string]int, len(ln.keyToIndex)),
+		length:       ln.length,
+		checksum:     ln.checksum,
+		checksumAlgo: ln.checksumAlgo,
+		dirty:        ln.dirty,
+		srcName:      ln.srcName,
+		srcLine:      ln.srcLine,
+		widthMode:    ln.widthMode,
+	}
+
+	copy(cpy.fields, ln.fields)
+	for k, i := range ln.keyToIndex {
+		cpy.keyToIndex[k] = i
+	}
+
+	if ln.tags != nil {
+		cpy.tags = make(map[string]string, len(ln.tags))
+		for k, v := range ln.tags {
+			cpy.tags[k] = v
+		}
+	}
+
+	return cpy
+}
+
+// MarshalJSON returns a line as a JSON array of fields. A tagged line is
+// instead wrapped as an object holding the fields and tags, so
+// annotations survive the round trip.
+func (ln *Line) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ln.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// appendJSON streams a line's JSON form into a buffer.
+func (ln *Line) appendJSON(buf *bytes.Buffer) error {
+	if 0 < len(ln.tags) {
+		buf.WriteString(`{"fields":`)
+	}
+
+	buf.WriteByte('[')
+	for i, f := range ln.fields {
+		if 0 < i {
+			buf.WriteByte(',')
+		}
+
+		if err := f.appendJSON(buf); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteByte(']')
+	if 0 < len(ln.tags) {
+		tags, err := json.Marshal(ln.tags)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString(`,"tags":`)
+		buf.Write(tags)
+		buf.WriteByte('}')
+	}
+
+	return nil
+}
+
+// UnmarshalJSON parses a line from a JSON array of fields, or from the
+// wrapped object form MarshalJSON emits for tagged lines.
+func (ln *Line) UnmarshalJSON(b []byte) error {
+	var fields []Field
+	ln.tags = nil
+	if err := json.Unmarshal(b, &fields); err != nil {
+		var aux struct {
+			Fields []Field           `json:"fields"`
+			Tags   map[string]string `json:"tags"`
+		}
+
+		if err := json.Unmarshal(b, &aux); err != nil {
+			return err
+		}
+
+		fields = aux.Fields
+		ln.tags = aux.Tags
+	}
+
+	ln.fields = fields
+	ln.keyToIndex = make(map[string]int, len(fields))
+	ln.length = 0
+	for i, f := range fields {
+		ln.keyToIndex[f.key] = i
+		if ln.length < f.index+f.length {
+			ln.length = f.index + f.length
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package flatfile
+
+import "container/list"
+
+// A lineCache is a bounded LRU of parsed lines keyed by line number.
+type lineCache struct {
+	capacity int
+	order    *list.List // front is most recent; values are *cacheEntry
+	entries  map[int]*list.Element
+}
+
+// A cacheEntry pairs a line number with its parsed line.
+type cacheEntry struct {
+	i  int
+	ln *Line
+}
+
+// newLineCache returns an empty cache holding at most capacity lines.
+func newLineCache(capacity int) *lineCache {
+	return &lineCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[int]*list.Element, capacity),
+	}
+}
+
+// get returns the cached line i, marking it most recently used.
+func (c *lineCache) get(i int) (*Line, bool) {
+	e, ok := c.entries[i]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).ln, true
+}
+
+// put caches line i, evicting the least recently used line when full.
+func (c *lineCache) put(i int, ln *Line) {
+	if e, ok := c.entries[i]; ok {
+		e.Value.(*cacheEntry).ln = ln
+		c.order.MoveToFront(e)
+		return
+	}
+
+	if c.capacity <= c.order.Len() {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.entries, last.Value.(*cacheEntry).i)
+	}
+
+	c.entries[i] = c.order.PushFront(&cacheEntry{i: i, ln: ln})
+}
@@ -0,0 +1,128 @@
+package flatfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// MarshalOptions select the shape of MarshalJSONWith output.
+type MarshalOptions struct {
+	// OmitLayout drops each field's index, length, and json type,
+	// leaving only keys and values, for consumers that only want the
+	// data.
+	OmitLayout bool
+	// Objects renders each line as one {"key": value, ...} object
+	// instead of an array of field objects. Layout is never emitted in
+	// this shape.
+	Objects bool
+	// Indent pretty-prints with the given indent per nesting level; the
+	// empty string emits compact output.
+	Indent string
+}
+
+// MarshalJSONWith returns a flat file as JSON shaped by the given
+// options. The zero options value matches MarshalJSON exactly; tagged
+// lines and file metadata wrap as they do there.
+func (ff *FlatFile) MarshalJSONWith(opts MarshalOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(4 * ff.ByteLen())
+	if 0 < len(ff.meta) {
+		meta, err := json.Marshal(ff.meta)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString(`{"meta":`)
+		buf.Write(meta)
+		buf.WriteString(`,"lines":`)
+	}
+
+	buf.WriteByte('[')
+	for i, ln := range ff.lines {
+		if 0 < i {
+			buf.WriteByte(',')
+		}
+
+		if err := ln.appendJSONWith(&buf, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte(']')
+	if 0 < len(ff.meta) {
+		buf.WriteByte('}')
+	}
+
+	if len(opts.Indent) == 0 {
+		return buf.Bytes(), nil
+	}
+
+	var pretty bytes.Buffer
+	pretty.Grow(2 * buf.Len())
+	if err := json.Indent(&pretty, buf.Bytes(), "", opts.Indent); err != nil {
+		return nil, err
+	}
+
+	return pretty.Bytes(), nil
+}
+
+// appendJSONWith streams a line's JSON form shaped by the given options.
+func (ln *Line) appendJSONWith(buf *bytes.Buffer, opts MarshalOptions) error {
+	if 0 < len(ln.tags) {
+		buf.WriteString(`{"fields":`)
+	}
+
+	open, shut := byte('['), byte(']')
+	if opts.Objects {
+		open, shut = '{', '}'
+	}
+
+	buf.WriteByte(open)
+	for i, f := range ln.fields {
+		if 0 < i {
+			buf.WriteByte(',')
+		}
+
+		if err := f.appendJSONWith(buf, opts); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteByte(shut)
+	if 0 < len(ln.tags) {
+		tags, err := json.Marshal(ln.tags)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString(`,"tags":`)
+		buf.Write(tags)
+		buf.WriteByte('}')
+	}
+
+	return nil
+}
+
+// appendJSONWith streams a field's JSON form shaped by the given
+// options.
+func (f Field) appendJSONWith(buf *bytes.Buffer, opts MarshalOptions) error {
+	switch {
+	case opts.Objects:
+		buf.WriteString(strconv.Quote(f.key))
+		buf.WriteByte(':')
+		return f.appendJSONValue(buf)
+	case opts.OmitLayout:
+		buf.WriteString(`{"key":`)
+		buf.WriteString(strconv.Quote(f.key))
+		buf.WriteString(`,"value":`)
+		if err := f.appendJSONValue(buf); err != nil {
+			return err
+		}
+
+		buf.WriteByte('}')
+		return nil
+	default:
+		return f.appendJSON(buf)
+	}
+}
@@ -0,0 +1,56 @@
+package flatfile
+
+import "fmt"
+
+// SetMeta attaches file-level metadata — a creation timestamp, source
+// system, schema version — to a flat file. Metadata never appears in
+// the rendered flat output, but is embedded in JSON and snapshot output
+// when present.
+func (ff *FlatFile) SetMeta(key, value string) {
+	if ff.meta == nil {
+		ff.meta = make(map[string]string)
+	}
+
+	ff.meta[key] = value
+}
+
+// Meta returns the metadata value with the given key, and whether it is
+// set.
+func (ff *FlatFile) Meta(key string) (string, bool) {
+	value, ok := ff.meta[key]
+	return value, ok
+}
+
+// Metadata returns a copy of a flat file's metadata.
+func (ff *FlatFile) Metadata() map[string]string {
+	meta := make(map[string]string, len(ff.meta))
+	for k, v := range ff.meta {
+		meta[k] = v
+	}
+
+	return meta
+}
+
+// MetaFromHeader parses the first line under the given header layout,
+// moves its values into the metadata map, and removes the line from the
+// body, for feeds that carry their metadata as a header record. It
+// errors without modifying the flat file if there is no first line or
+// it is too short for the header layout.
+func (ff *FlatFile) MetaFromHeader(lf LineFmt) error {
+	if len(ff.lines) == 0 {
+		return fmt.Errorf("flatfile: no header line to promote")
+	}
+
+	hdr, err := NewLineSafe(lf, ff.lines[0].String())
+	if err != nil {
+		return err
+	}
+
+	ff.own()
+	for key, value := range hdr.KeyValues() {
+		ff.SetMeta(key, value)
+	}
+
+	ff.lines = ff.lines[1:]
+	return nil
+}
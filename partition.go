@@ -0,0 +1,74 @@
+package flatfile
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// WritePartitioned splits a flat file into one file per distinct value
+// of key, written into dir as <value>.txt — feeding, say, one file per
+// region to per-region consumers. Filenames keep letters, digits,
+// dashes, underscores, and dots from the value and replace the rest
+// with underscores; a blank value writes to _.txt. Each partition
+// inherits the receiver's trailer configuration, so per-partition
+// trailers are generated from each partition's own statistics.
+func (ff *FlatFile) WritePartitioned(dir, key string) error {
+	for value, part := range ff.GroupBy(key) {
+		part.trailerFmt, part.trailerFill = ff.trailerFmt, ff.trailerFill
+		if err := part.WriteFile(filepath.Join(dir, partitionName(value)+".txt")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SplitFiles writes a flat file as numbered files — prefix-0001.txt,
+// prefix-0002.txt, and so on — of at most maxLines lines each, for
+// transfer partners that reject files over a size limit. Each chunk
+// inherits the receiver's trailer configuration, so per-chunk trailers
+// are generated from each chunk's own statistics. It returns the paths
+// written, none for an empty flat file.
+func (ff *FlatFile) SplitFiles(prefix string, maxLines int) ([]string, error) {
+	if maxLines < 1 {
+		return nil, fmt.Errorf("flatfile: cannot split into files of %d lines", maxLines)
+	}
+
+	var paths []string
+	for start := 0; start < len(ff.lines); start += maxLines {
+		end := start + maxLines
+		if len(ff.lines) < end {
+			end = len(ff.lines)
+		}
+
+		chunk := ff.Slice(start, end)
+		chunk.trailerFmt, chunk.trailerFill = ff.trailerFmt, ff.trailerFill
+		path := fmt.Sprintf("%s-%04d.txt", prefix, len(paths)+1)
+		if err := chunk.WriteFile(path); err != nil {
+			return paths, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// partitionName sanitizes a field value into a filename stem.
+func partitionName(value string) string {
+	if len(value) == 0 {
+		return "_"
+	}
+
+	b := []byte(value)
+	for i, c := range b {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.':
+		default:
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,179 @@
+package flatfile
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// An Anomalies report summarizes the physical shape of a raw flat file:
+// how line lengths are distributed, which lines the formatter rejected,
+// and which fields were blank on every line that parsed. It is the first
+// diagnostic to run against an unfamiliar feed.
+type Anomalies struct {
+	LineLengths map[int]int // physical line length to occurrence count
+	Rejected    []int       // 1-based numbers of lines too short for their format
+	BlankFields []string    // fields blank in every parsed line, in field order
+}
+
+// Analyze reads raw lines and reports their length distribution, the
+// lines rejected under the given formatter, and the fields that never
+// held a value. Empty lines are skipped, matching ReadFrom.
+func Analyze(r io.Reader, fmtr Formatter) (Anomalies, error) {
+	anom := Anomalies{LineLengths: make(map[int]int)}
+
+	var (
+		lineNo     int
+		order      []string
+		everFilled = make(map[string]bool)
+	)
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lineNo++
+		line := sc.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		anom.LineLengths[len(line)]++
+
+		lf := fmtr(line)
+		rejected := false
+		for _, f := range lf {
+			if !f.fits(len(line)) {
+				rejected = true
+				break
+			}
+		}
+
+		if rejected {
+			anom.Rejected = append(anom.Rejected, lineNo)
+			continue
+		}
+
+		for _, f := range NewLine(lf, line).fields {
+			if _, ok := everFilled[f.key]; !ok {
+				everFilled[f.key] = false
+				order = append(order, f.key)
+			}
+
+			if 0 < len(f.value) {
+				everFilled[f.key] = true
+			}
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return anom, err
+	}
+
+	for _, key := range order {
+		if !everFilled[key] {
+			anom.BlankFields = append(anom.BlankFields, key)
+		}
+	}
+
+	return anom, nil
+}
+
+// distinctCap bounds the number of distinct values tracked per field
+// while profiling, keeping memory flat on high-cardinality fields.
+const distinctCap = 1000
+
+// A FieldProfile summarizes one field's values across a flat file.
+type FieldProfile struct {
+	Key            string
+	Count          int  // lines holding the field
+	Filled         int  // non-blank values
+	MinLen         int  // length of the shortest non-blank value
+	MaxLen         int  // length of the longest non-blank value
+	Distinct       int  // distinct values seen, capped at distinctCap
+	DistinctCapped bool // true if more than distinctCap distinct values exist
+	Numeric        bool // every non-blank value parsed as a number
+	NumericMin     float64
+	NumericMax     float64
+}
+
+// FillRate returns the fraction of lines holding the field in which its
+// value is not blank.
+func (p FieldProfile) FillRate() float64 {
+	if p.Count == 0 {
+		return 0
+	}
+
+	return float64(p.Filled) / float64(p.Count)
+}
+
+// Profile returns a profile of every field key encountered across a flat
+// file's lines, in first-seen field order — the quickest sanity check of
+// an unfamiliar feed.
+func (ff *FlatFile) Profile() []FieldProfile {
+	var (
+		order    []string
+		profiles = make(map[string]*FieldProfile)
+		distinct = make(map[string]map[string]bool)
+	)
+
+	for _, ln := range ff.lines {
+		for _, f := range ln.fields {
+			p, ok := profiles[f.key]
+			if !ok {
+				p = &FieldProfile{Key: f.key, Numeric: true}
+				profiles[f.key] = p
+				distinct[f.key] = make(map[string]bool)
+				order = append(order, f.key)
+			}
+
+			p.Count++
+			if len(f.value) == 0 {
+				continue
+			}
+
+			p.Filled++
+			if p.Filled == 1 || len(f.value) < p.MinLen {
+				p.MinLen = len(f.value)
+			}
+
+			if p.MaxLen < len(f.value) {
+				p.MaxLen = len(f.value)
+			}
+
+			d := distinct[f.key]
+			if len(d) < distinctCap {
+				d[f.value] = true
+			} else if !d[f.value] {
+				p.DistinctCapped = true
+			}
+
+			if p.Numeric {
+				x, err := strconv.ParseFloat(f.value, 64)
+				if err != nil {
+					p.Numeric = false
+					continue
+				}
+
+				if p.Filled == 1 || x < p.NumericMin {
+					p.NumericMin = x
+				}
+
+				if p.Filled == 1 || p.NumericMax < x {
+					p.NumericMax = x
+				}
+			}
+		}
+	}
+
+	out := make([]FieldProfile, 0, len(order))
+	for _, key := range order {
+		p := profiles[key]
+		p.Distinct = len(distinct[key])
+		if p.Filled == 0 {
+			p.Numeric = false
+		}
+
+		out = append(out, *p)
+	}
+
+	return out
+}
@@ -0,0 +1,121 @@
+package flatfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ProtoSchema renders a layout as a proto3 message definition, so teams
+// feeding fixed-width data into gRPC pipelines can generate bindings
+// from the same layout that parses the file. Str fields map to string,
+// Num to double, and Bool to bool; field numbers follow layout order and
+// keys are sanitized to proto identifiers.
+func ProtoSchema(name string, layout []Format) string {
+	var sb strings.Builder
+	sb.WriteString("syntax = \"proto3\";\n\nmessage " + name + " {\n")
+	for i, f := range layout {
+		var kind string
+		switch f.jsonType {
+		case Num:
+			kind = "double"
+		case Bool:
+			kind = "bool"
+		default:
+			kind = "string"
+		}
+
+		fmt.Fprintf(&sb, "  %s %s = %d;\n", kind, protoName(f.key), i+1)
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// protoName sanitizes a field key to a proto identifier.
+func protoName(key string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(key) {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+
+	name := sb.String()
+	if len(name) == 0 || '0' <= name[0] && name[0] <= '9' {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// ProtoMessage renders the ith line as a proto3 wire-format message
+// matching the schema from ProtoSchema. Blank values are omitted, which
+// proto3 reads back as each type's zero value.
+func (ff *FlatFile) ProtoMessage(i int) ([]byte, error) {
+	var b []byte
+	for n, f := range ff.lines[i].fields {
+		if len(f.value) == 0 {
+			continue
+		}
+
+		num := uint64(n + 1)
+		switch f.jsonType {
+		case Num:
+			v, err := strconv.ParseFloat(f.value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: field %q: %w", i, f.key, err)
+			}
+
+			b = binary.AppendUvarint(b, num<<3|1)
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		case Bool:
+			v, err := strconv.ParseBool(strings.ToLower(f.value))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: field %q: %w", i, f.key, err)
+			}
+
+			b = binary.AppendUvarint(b, num<<3)
+			if v {
+				b = append(b, 1)
+			} else {
+				b = append(b, 0)
+			}
+		default:
+			b = binary.AppendUvarint(b, num<<3|2)
+			b = binary.AppendUvarint(b, uint64(len(f.value)))
+			b = append(b, f.value...)
+		}
+	}
+
+	return b, nil
+}
+
+// WriteProtoDelimited writes every line as a varint length-delimited
+// proto3 message, the framing gRPC tooling and protodelim readers
+// expect for message streams.
+func (ff *FlatFile) WriteProtoDelimited(w io.Writer) error {
+	var prefix [binary.MaxVarintLen64]byte
+	for i := range ff.lines {
+		msg, err := ff.ProtoMessage(i)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(prefix[:binary.PutUvarint(prefix[:], uint64(len(msg)))]); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
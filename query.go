@@ -0,0 +1,227 @@
+package flatfile
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// numericValues returns the parsed numeric value of the given field in
+// every line, skipping blank values. It errors on the first value that
+// does not parse as a number.
+func (ff *FlatFile) numericValues(key string) ([]float64, error) {
+	values := make([]float64, 0, len(ff.lines))
+	for i, ln := range ff.lines {
+		v, err := ln.Get(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(v) == 0 {
+			continue
+		}
+
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("flatfile: line %d: field %q value %q is not numeric", i, key, v)
+		}
+
+		values = append(values, x)
+	}
+
+	return values, nil
+}
+
+// Column returns the value of the given field in every line, in line
+// order. It errors if any line lacks the field.
+func (ff *FlatFile) Column(key string) ([]string, error) {
+	values := make([]string, 0, len(ff.lines))
+	for _, ln := range ff.lines {
+		v, err := ln.Get(key)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// NumericColumn returns the parsed numeric value of the given field in
+// every line, in line order, skipping blank values.
+func (ff *FlatFile) NumericColumn(key string) ([]float64, error) {
+	return ff.numericValues(key)
+}
+
+// Count returns the number of lines in which the given field is not
+// blank.
+func (ff *FlatFile) Count(key string) int {
+	var n int
+	for _, ln := range ff.lines {
+		if v, _ := ln.Get(key); 0 < len(v) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Sum returns the sum of the given field's numeric values, skipping
+// blanks.
+func (ff *FlatFile) Sum(key string) (float64, error) {
+	values, err := ff.numericValues(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var sum float64
+	for _, x := range values {
+		sum += x
+	}
+
+	return sum, nil
+}
+
+// Min returns the smallest of the given field's numeric values, skipping
+// blanks. It errors if the field holds no values.
+func (ff *FlatFile) Min(key string) (float64, error) {
+	values, err := ff.numericValues(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(values) == 0 {
+		return 0, fmt.Errorf("flatfile: field %q holds no values", key)
+	}
+
+	min := values[0]
+	for _, x := range values[1:] {
+		if x < min {
+			min = x
+		}
+	}
+
+	return min, nil
+}
+
+// Max returns the largest of the given field's numeric values, skipping
+// blanks. It errors if the field holds no values.
+func (ff *FlatFile) Max(key string) (float64, error) {
+	values, err := ff.numericValues(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(values) == 0 {
+		return 0, fmt.Errorf("flatfile: field %q holds no values", key)
+	}
+
+	max := values[0]
+	for _, x := range values[1:] {
+		if max < x {
+			max = x
+		}
+	}
+
+	return max, nil
+}
+
+// Avg returns the mean of the given field's numeric values, skipping
+// blanks. It errors if the field holds no values.
+func (ff *FlatFile) Avg(key string) (float64, error) {
+	values, err := ff.numericValues(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(values) == 0 {
+		return 0, fmt.Errorf("flatfile: field %q holds no values", key)
+	}
+
+	var sum float64
+	for _, x := range values {
+		sum += x
+	}
+
+	return sum / float64(len(values)), nil
+}
+
+// BuildIndex builds (or rebuilds) a hash index from the given field's
+// values to the indices of the lines holding them. Any mutation of the
+// flat file drops its indexes; rebuild after loading or editing.
+func (ff *FlatFile) BuildIndex(key string) error {
+	idx := make(map[string][]int)
+	for i, ln := range ff.lines {
+		v, err := ln.Get(key)
+		if err != nil {
+			return err
+		}
+
+		idx[v] = append(idx[v], i)
+	}
+
+	if ff.indexes == nil {
+		ff.indexes = make(map[string]map[string][]int)
+	}
+
+	ff.indexes[key] = idx
+	return nil
+}
+
+// LookupIndexed returns the indices of the lines whose given field holds
+// the given value, in line order. It errors if no index has been built
+// on the field.
+func (ff *FlatFile) LookupIndexed(key, value string) ([]int, error) {
+	idx, ok := ff.indexes[key]
+	if !ok {
+		return nil, fmt.Errorf("flatfile: no index on field %q", key)
+	}
+
+	return append([]int(nil), idx[value]...), nil
+}
+
+// LinesWhere returns copies of the lines whose given field holds the
+// given value, in line order. It is backed by the field's index when one
+// has been built and falls back to a linear scan otherwise.
+func (ff *FlatFile) LinesWhere(key, value string) []*Line {
+	if idx, ok := ff.indexes[key]; ok {
+		lns := make([]*Line, 0, len(idx[value]))
+		for _, i := range idx[value] {
+			lns = append(lns, ff.lines[i].Copy())
+		}
+
+		return lns
+	}
+
+	var lns []*Line
+	for _, ln := range ff.lines {
+		if v, _ := ln.Get(key); v == value {
+			lns = append(lns, ln.Copy())
+		}
+	}
+
+	return lns
+}
+
+// GroupBy partitions a flat file's lines by the value of the given
+// field, returning a shared-storage view per distinct value. Lines
+// missing the field are grouped under the empty string.
+func (ff *FlatFile) GroupBy(key string) map[string]*FlatFile {
+	groups := make(map[string]*FlatFile)
+	for _, ln := range ff.lines {
+		v, _ := ln.Get(key)
+		g, ok := groups[v]
+		if !ok {
+			g = &FlatFile{
+				fmtr:   ff.fmtr,
+				shared: true,
+			}
+			groups[v] = g
+		}
+
+		g.lines = append(g.lines, ln)
+	}
+
+	ff.shared = true
+	return groups
+}
@@ -0,0 +1,59 @@
+package flatfile
+
+import "io"
+
+// Reader returns a reader streaming a flat file as WriteTo renders it —
+// each line newline-terminated, trailer last — rendering one line at a
+// time, so a large flat file can be handed to an HTTP upload or a hash
+// without materializing the full Bytes slice. Mutating the flat file
+// mid-read gives unspecified results.
+func (ff *FlatFile) Reader() io.Reader {
+	return &fileReader{ff: ff}
+}
+
+// A fileReader streams a flat file line by line.
+type fileReader struct {
+	ff      *FlatFile
+	i       int    // next line to render
+	buf     []byte // unread remainder of the current line
+	trailed bool   // trailer already rendered
+}
+
+// Read implements io.Reader.
+func (r *fileReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		switch {
+		case r.i < len(r.ff.lines):
+			s, err := r.ff.enforceWidth(r.ff.lines[r.i].String(), r.i)
+			if err != nil {
+				return 0, err
+			}
+
+			r.buf = append(r.buf, s...)
+			r.buf = append(r.buf, '\n')
+			r.i++
+		case !r.trailed:
+			r.trailed = true
+			tln, err := r.ff.trailerLine()
+			if err != nil {
+				return 0, err
+			}
+
+			if tln != nil {
+				s, err := r.ff.enforceWidth(tln.String(), -1)
+				if err != nil {
+					return 0, err
+				}
+
+				r.buf = append(r.buf, s...)
+				r.buf = append(r.buf, '\n')
+			}
+		default:
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
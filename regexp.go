@@ -0,0 +1,48 @@
+package flatfile
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RegexpFormatter returns a formatter that lays out each line from the
+// pattern's capture groups: group n becomes a format at the group's
+// matched position and width, handy for semi-fixed files where field
+// boundaries are best described by a pattern. Keys name the groups in
+// order; a group beyond the given keys falls back to its name in the
+// pattern, or group<n>. A line the pattern does not match, and any
+// group that does not participate in the match, yields no fields. All
+// fields are Str typed.
+func RegexpFormatter(re *regexp.Regexp, keys ...string) Formatter {
+	return func(line string) LineFmt {
+		m := re.FindStringSubmatchIndex(line)
+		if m == nil {
+			return nil
+		}
+
+		var (
+			lf    LineFmt
+			names = re.SubexpNames()
+		)
+
+		for g := 1; g <= re.NumSubexp(); g++ {
+			start, end := m[2*g], m[2*g+1]
+			if start < 0 {
+				continue
+			}
+
+			key := names[g]
+			if g-1 < len(keys) {
+				key = keys[g-1]
+			}
+
+			if len(key) == 0 {
+				key = fmt.Sprintf("group%d", g)
+			}
+
+			lf = append(lf, NewFormat(key, start, end-start, Str))
+		}
+
+		return lf
+	}
+}
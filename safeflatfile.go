@@ -0,0 +1,197 @@
+package flatfile
+
+import (
+	"io"
+	"sync"
+)
+
+// A SafeFlatFile wraps a flat file with a read-write mutex, making it
+// safe for concurrent use by multiple goroutines.
+type SafeFlatFile struct {
+	mu sync.RWMutex
+	ff *FlatFile
+}
+
+// NewSafe returns an empty concurrency-safe flat file that parses lines
+// with the given formatter.
+func NewSafe(fmtr Formatter) *SafeFlatFile {
+	return &SafeFlatFile{ff: New(fmtr)}
+}
+
+// Safe wraps an existing flat file. The caller must not retain its own
+// reference to the flat file.
+func Safe(ff *FlatFile) *SafeFlatFile {
+	return &SafeFlatFile{ff: ff}
+}
+
+// Append parses each raw line with the flat file's formatter and appends
+// the result.
+func (sff *SafeFlatFile) Append(lines ...string) {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	sff.ff.Append(lines...)
+}
+
+// AppendLines appends already parsed lines.
+func (sff *SafeFlatFile) AppendLines(lns ...*Line) {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	sff.ff.AppendLines(lns...)
+}
+
+// Len returns the number of lines in a flat file.
+func (sff *SafeFlatFile) Len() int {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	return sff.ff.Len()
+}
+
+// Line returns a copy of the ith line.
+func (sff *SafeFlatFile) Line(i int) *Line {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	return sff.ff.Line(i)
+}
+
+// Get returns the value of the field having the given key in the ith
+// line.
+func (sff *SafeFlatFile) Get(i int, key string) (string, error) {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	return sff.ff.Get(i, key)
+}
+
+// Set updates the value of the field having the given key in the ith
+// line.
+func (sff *SafeFlatFile) Set(i int, key, value string) error {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	return sff.ff.Set(i, key, value)
+}
+
+// KeyValues returns a map of each field's key to its value in the ith
+// line.
+func (sff *SafeFlatFile) KeyValues(i int) map[string]string {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	return sff.ff.KeyValues(i)
+}
+
+// Remove removes and returns the ith line.
+func (sff *SafeFlatFile) Remove(i int) *Line {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	return sff.ff.Remove(i)
+}
+
+// Sort sorts the lines of a flat file by the given less function.
+func (sff *SafeFlatFile) Sort(less func(ln0, ln1 *Line) bool) {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	sff.ff.Sort(less)
+}
+
+// ReadFrom reads and appends each non-empty line from a reader.
+func (sff *SafeFlatFile) ReadFrom(r io.Reader) (int64, error) {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	return sff.ff.ReadFrom(r)
+}
+
+// ReadFile reads and appends each line of a file.
+func (sff *SafeFlatFile) ReadFile(path string) error {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	return sff.ff.ReadFile(path)
+}
+
+// WriteTo writes each line to a writer, terminated with newlines. It
+// takes the write lock, not the read lock, because a full write marks
+// every line clean.
+func (sff *SafeFlatFile) WriteTo(w io.Writer) (int64, error) {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	return sff.ff.WriteTo(w)
+}
+
+// WriteFile writes a flat file to a file. Like WriteTo, it takes the
+// write lock.
+func (sff *SafeFlatFile) WriteFile(path string) error {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	return sff.ff.WriteFile(path)
+}
+
+// String returns a flat file rendered with each line terminated by a
+// newline.
+func (sff *SafeFlatFile) String() string {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	return sff.ff.String()
+}
+
+// Bytes returns a flat file rendered with each line terminated by a
+// newline.
+func (sff *SafeFlatFile) Bytes() []byte {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	return sff.ff.Bytes()
+}
+
+// ByteLen returns the length of a flat file's string representation.
+func (sff *SafeFlatFile) ByteLen() int {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	return sff.ff.ByteLen()
+}
+
+// MarshalJSON returns a flat file as a JSON array of lines.
+func (sff *SafeFlatFile) MarshalJSON() ([]byte, error) {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	return sff.ff.MarshalJSON()
+}
+
+// Snapshot returns a copy-on-write snapshot of the underlying flat
+// file. The snapshot itself is not guarded by the mutex.
+func (sff *SafeFlatFile) Snapshot() *FlatFile {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	return sff.ff.Snapshot()
+}
+
+// Update calls fn with the underlying flat file while holding the write
+// lock, allowing several operations to be applied atomically.
+func (sff *SafeFlatFile) Update(fn func(ff *FlatFile)) {
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	fn(sff.ff)
+}
+
+// View calls fn with the underlying flat file while holding the read
+// lock. The flat file must not be mutated within fn.
+func (sff *SafeFlatFile) View(fn func(ff *FlatFile)) {
+	sff.mu.RLock()
+	defer sff.mu.RUnlock()
+
+	fn(sff.ff)
+}
@@ -0,0 +1,54 @@
+package flatfile
+
+import "math/rand"
+
+// Shuffle permutes a flat file's lines in place. The same seed over the
+// same lines produces the same permutation, so randomized fixtures stay
+// reproducible.
+func (ff *FlatFile) Shuffle(seed int64) {
+	ff.own()
+
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(ff.lines), func(i, j int) {
+		ff.lines[i], ff.lines[j] = ff.lines[j], ff.lines[i]
+	})
+}
+
+// Sample returns a new flat file holding copies of n lines chosen by
+// reservoir sampling, so every line is equally likely to be selected.
+// The same seed over the same lines selects the same sample. If the flat
+// file holds n lines or fewer, every line is copied; a negative n is
+// treated as zero.
+func (ff *FlatFile) Sample(n int, seed int64) *FlatFile {
+	sampled := New(ff.fmtr)
+	if n < 0 {
+		n = 0
+	}
+
+	if len(ff.lines) <= n {
+		for _, ln := range ff.lines {
+			sampled.lines = append(sampled.lines, ln.Copy())
+		}
+
+		return sampled
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	reservoir := make([]*Line, n)
+	for i, ln := range ff.lines {
+		switch {
+		case i < n:
+			reservoir[i] = ln
+		default:
+			if j := rng.Intn(i + 1); j < n {
+				reservoir[j] = ln
+			}
+		}
+	}
+
+	for _, ln := range reservoir {
+		sampled.lines = append(sampled.lines, ln.Copy())
+	}
+
+	return sampled
+}
@@ -0,0 +1,238 @@
+package flatfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenameKey changes the key a field is retrieved by in every line. It
+// errors on the first line missing the old key or already holding the
+// new one; earlier lines keep the new key.
+func (ff *FlatFile) RenameKey(old, new string) error {
+	ff.own()
+
+	for i, ln := range ff.lines {
+		if err := ln.RenameKey(old, new); err != nil {
+			return fmt.Errorf("line %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// AddField appends a field of the given extent to every line, filling it
+// with the value compute derives from the line's existing fields. Lines
+// grow to cover the new extent. It errors on the first line already
+// holding the key or whose computed value does not fit; earlier lines
+// keep the new field.
+func (ff *FlatFile) AddField(key string, index, length int, compute func(ln *Line) string) error {
+	ff.own()
+
+	f := NewFormat(key, index, length, Str)
+	for i, ln := range ff.lines {
+		if _, ok := ln.keyToIndex[key]; ok {
+			return fmt.Errorf("line %d: field with key %q already exists", i, key)
+		}
+
+		v := compute(ln.Copy())
+		if length < len(v) {
+			return fmt.Errorf("line %d: computed value %q overflows field %q of length %d", i, v, key, length)
+		}
+
+		ln.keyToIndex[key] = len(ln.fields)
+		ln.fields = append(ln.fields, NewField(f, v))
+		if ln.length < index+length {
+			ln.length = index + length
+		}
+	}
+
+	return nil
+}
+
+// SplitField splits one field into several fields of fixed sub-widths
+// covering the original extent, in every line. The widths must sum to
+// the original field's length, and each new key pairs with the width at
+// the same position. Sub-values are cut from the field's rendered
+// (padded) value and trimmed like freshly parsed fields.
+func (ff *FlatFile) SplitField(key string, keys []string, widths []int) error {
+	if len(keys) != len(widths) {
+		return fmt.Errorf("flatfile: %d keys for %d widths", len(keys), len(widths))
+	}
+
+	ff.own()
+
+	for i, ln := range ff.lines {
+		j, ok := ln.keyToIndex[key]
+		if !ok {
+			return fmt.Errorf("line %d: no field with key %q", i, key)
+		}
+
+		f := ln.fields[j]
+		var total int
+		for _, w := range widths {
+			total += w
+		}
+
+		if total != f.length {
+			return fmt.Errorf("line %d: widths sum to %d, field %q has length %d", i, total, key, f.length)
+		}
+
+		rendered := f.String()
+		repl := make([]Field, 0, len(keys))
+		offset := 0
+		for k, newKey := range keys {
+			if _, ok := ln.keyToIndex[newKey]; ok && newKey != key {
+				return fmt.Errorf("line %d: field with key %q already exists", i, newKey)
+			}
+
+			sub := NewFormat(newKey, f.index+offset, widths[k], f.jsonType)
+			repl = append(repl, NewField(sub, strings.TrimSpace(rendered[offset:offset+widths[k]])))
+			offset += widths[k]
+		}
+
+		fields := append(ln.fields[:j:j], append(repl, ln.fields[j+1:]...)...)
+		origLen := ln.length
+		*ln = *newLineFromFields(fields)
+		if ln.length < origLen {
+			ln.length = origLen
+		}
+	}
+
+	return nil
+}
+
+// MergeFields merges fields with contiguous extents into a single field
+// with the given key, in every line. The keys must name fields that are
+// adjacent in field order and whose extents abut. The merged value is
+// the concatenation of the rendered values, trimmed like a freshly
+// parsed field.
+func (ff *FlatFile) MergeFields(newKey string, keys ...string) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("flatfile: no fields to merge")
+	}
+
+	ff.own()
+
+	for i, ln := range ff.lines {
+		j, ok := ln.keyToIndex[keys[0]]
+		if !ok {
+			return fmt.Errorf("line %d: no field with key %q", i, keys[0])
+		}
+
+		var (
+			first    = ln.fields[j]
+			length   int
+			rendered strings.Builder
+		)
+
+		for k, key := range keys {
+			idx, ok := ln.keyToIndex[key]
+			if !ok {
+				return fmt.Errorf("line %d: no field with key %q", i, key)
+			}
+
+			if idx != j+k {
+				return fmt.Errorf("line %d: field %q is not adjacent in field order", i, key)
+			}
+
+			f := ln.fields[idx]
+			if f.index != first.index+length {
+				return fmt.Errorf("line %d: field %q does not abut the preceding field", i, key)
+			}
+
+			length += f.length
+			rendered.WriteString(f.String())
+		}
+
+		merged := NewField(NewFormat(newKey, first.index, length, first.jsonType), strings.TrimSpace(rendered.String()))
+		fields := append(ln.fields[:j:j], append([]Field{merged}, ln.fields[j+len(keys):]...)...)
+		origLen := ln.length
+		*ln = *newLineFromFields(fields)
+		if ln.length < origLen {
+			ln.length = origLen
+		}
+	}
+
+	return nil
+}
+
+// ReorderFields rearranges every line's fields into the given key order,
+// recomputing indices contiguously from zero so the output column order
+// changes without re-parsing. The keys must name every field of each
+// line exactly once.
+func (ff *FlatFile) ReorderFields(keys ...string) error {
+	ff.own()
+
+	for i, ln := range ff.lines {
+		if len(keys) != len(ln.fields) {
+			return fmt.Errorf("line %d: %d keys for %d fields", i, len(keys), len(ln.fields))
+		}
+
+		var (
+			offset int
+			fields = make([]Field, 0, len(keys))
+			seen   = make(map[string]bool, len(keys))
+		)
+
+		for _, key := range keys {
+			j, ok := ln.keyToIndex[key]
+			if !ok || seen[key] {
+				return fmt.Errorf("line %d: keys must name every field exactly once, got %q", i, key)
+			}
+
+			seen[key] = true
+			f := ln.fields[j]
+			f.index = offset
+			offset += f.length
+			fields = append(fields, f)
+		}
+
+		*ln = *newLineFromFields(fields)
+	}
+
+	return nil
+}
+
+// Reformat rewrites every line into the target layout, returning a new
+// flat file. Each target field takes its value from the source field
+// named by mapping, or from the source field with the same key when the
+// mapping has no entry; fields with no source are left blank. A value
+// longer than its target field aborts with an error.
+func (ff *FlatFile) Reformat(target []Format, mapping map[string]string) (*FlatFile, error) {
+	return ff.reformat(target, mapping, false)
+}
+
+// ReformatTruncate rewrites like Reformat, but clips values that are
+// longer than their target field instead of returning an error.
+func (ff *FlatFile) ReformatTruncate(target []Format, mapping map[string]string) (*FlatFile, error) {
+	return ff.reformat(target, mapping, true)
+}
+
+func (ff *FlatFile) reformat(target []Format, mapping map[string]string, truncate bool) (*FlatFile, error) {
+	tlf := LineFmt(append([]Format(nil), target...))
+	out := New(func(string) LineFmt { return tlf })
+	for i, ln := range ff.lines {
+		fields := make([]Field, 0, len(target))
+		for _, f := range target {
+			src := f.key
+			if m, ok := mapping[f.key]; ok {
+				src = m
+			}
+
+			v, _ := ln.Get(src)
+			if f.length < len(v) {
+				if !truncate {
+					return nil, fmt.Errorf("flatfile: line %d: value %q overflows target field %q of length %d", i, v, f.key, f.length)
+				}
+
+				v = v[:f.length]
+			}
+
+			fields = append(fields, NewField(f, v))
+		}
+
+		out.lines = append(out.lines, newLineFromFields(fields))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,120 @@
+package flatfile
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A FlatFileSet manages related named flat files — a customers extract
+// alongside its orders extract, say — so multi-file feeds can be
+// loaded, validated, and cross-checked as a unit.
+type FlatFileSet struct {
+	fmtrs map[string]Formatter
+	files map[string]*FlatFile
+}
+
+// NewSet returns an empty flat file set.
+func NewSet() *FlatFileSet {
+	return &FlatFileSet{
+		fmtrs: make(map[string]Formatter),
+		files: make(map[string]*FlatFile),
+	}
+}
+
+// Define registers a named file and the formatter that parses it,
+// replacing any file already loaded under the name.
+func (s *FlatFileSet) Define(name string, fmtr Formatter) {
+	s.fmtrs[name] = fmtr
+	s.files[name] = New(fmtr)
+}
+
+// File returns the named flat file, and whether the name is defined.
+func (s *FlatFileSet) File(name string) (*FlatFile, bool) {
+	ff, ok := s.files[name]
+	return ff, ok
+}
+
+// Names returns the defined names in sorted order.
+func (s *FlatFileSet) Names() []string {
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Load reads each named path into its defined file, replacing previous
+// contents. Every name must be defined; errors name the file they came
+// from.
+func (s *FlatFileSet) Load(paths map[string]string) error {
+	for name := range paths {
+		if _, ok := s.fmtrs[name]; !ok {
+			return fmt.Errorf("flatfile: set holds no definition for %q", name)
+		}
+	}
+
+	for name, path := range paths {
+		ff := New(s.fmtrs[name])
+		if err := ff.ReadFile(path); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		s.files[name] = ff
+	}
+
+	return nil
+}
+
+// Validate runs a rule against every file in the set, in name order,
+// and joins the failures, each prefixed with its file's name.
+func (s *FlatFileSet) Validate(rule func(name string, ff *FlatFile) error) error {
+	var errs []error
+	for _, name := range s.Names() {
+		if err := rule(name, s.files[name]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CrossCheck verifies referential integrity across the set: every value
+// of key in the child file must appear as a value of key in the parent
+// file. Blank child values are ignored.
+func (s *FlatFileSet) CrossCheck(parent, child, key string) error {
+	pff, ok := s.files[parent]
+	if !ok {
+		return fmt.Errorf("flatfile: set holds no definition for %q", parent)
+	}
+
+	cff, ok := s.files[child]
+	if !ok {
+		return fmt.Errorf("flatfile: set holds no definition for %q", child)
+	}
+
+	known, err := pff.Column(key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", parent, err)
+	}
+
+	keys := make(map[string]bool, len(known))
+	for _, v := range known {
+		keys[v] = true
+	}
+
+	for i := 0; i < cff.Len(); i++ {
+		v, err := cff.Get(i, key)
+		if err != nil {
+			return fmt.Errorf("%s: line %d: %w", child, i, err)
+		}
+
+		if 0 < len(v) && !keys[v] {
+			return fmt.Errorf("flatfile: %s line %d holds %s %q not present in %s", child, i, key, v, parent)
+		}
+	}
+
+	return nil
+}
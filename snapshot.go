@@ -0,0 +1,41 @@
+package flatfile
+
+// Snapshot returns a flat file sharing the receiver's line storage. The
+// snapshot is cheap to take: lines are only copied when either side is
+// first mutated, so a snapshot remains a stable view of the file as it
+// was while the original continues to change.
+func (ff *FlatFile) Snapshot() *FlatFile {
+	ff.shared = true
+	snap := &FlatFile{
+		fmtr:        ff.fmtr,
+		lines:       ff.lines,
+		shared:      true,
+		trailerFmt:  ff.trailerFmt,
+		trailerFill: ff.trailerFill,
+	}
+
+	if ff.meta != nil {
+		snap.meta = ff.Metadata()
+	}
+
+	return snap
+}
+
+// own deep copies a flat file's line storage if it is shared with a
+// snapshot, and drops any secondary indexes, which a mutation would
+// leave stale. Every mutating method must call own before modifying
+// lines.
+func (ff *FlatFile) own() {
+	ff.indexes = nil
+	if !ff.shared {
+		return
+	}
+
+	lines := make([]*Line, len(ff.lines))
+	for i, ln := range ff.lines {
+		lines[i] = ln.Copy()
+	}
+
+	ff.lines = lines
+	ff.shared = false
+}
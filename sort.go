@@ -0,0 +1,93 @@
+package flatfile
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Collator compares strings under locale-specific collation rules so
+// that accented and case-variant values sort per the language's
+// conventions rather than byte order. It is satisfied by
+// *golang.org/x/text/collate.Collator.
+type Collator interface {
+	CompareString(a, b string) int
+}
+
+// compareFields compares two values of the same field: numerically when
+// the field is numeric and both values parse, under the collator when
+// one is given, and byte-wise otherwise.
+func compareFields(f Format, v0, v1 string, col Collator) int {
+	if f.jsonType == Num {
+		x0, err0 := strconv.ParseFloat(v0, 64)
+		x1, err1 := strconv.ParseFloat(v1, 64)
+		if err0 == nil && err1 == nil {
+			switch {
+			case x0 < x1:
+				return -1
+			case x1 < x0:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	if col != nil {
+		return col.CompareString(v0, v1)
+	}
+
+	return strings.Compare(v0, v1)
+}
+
+// compareByKeys compares two lines field by field over the given keys,
+// with earlier keys taking precedence.
+func compareByKeys(ln0, ln1 *Line, keys []string, col Collator) int {
+	for _, key := range keys {
+		i, ok := ln0.keyToIndex[key]
+		if !ok {
+			continue
+		}
+
+		v0, _ := ln0.Get(key)
+		v1, _ := ln1.Get(key)
+		if c := compareFields(ln0.fields[i].Format, v0, v1, col); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// SortByKeys sorts a flat file's lines lexicographically by the given
+// fields, with earlier keys taking precedence. Numeric fields are
+// compared by value rather than byte order.
+func (ff *FlatFile) SortByKeys(keys ...string) {
+	ff.Sort(func(ln0, ln1 *Line) bool { return compareByKeys(ln0, ln1, keys, nil) < 0 })
+}
+
+// SortByKeysCollated sorts like SortByKeys, but compares non-numeric
+// fields with the given collator.
+func (ff *FlatFile) SortByKeysCollated(col Collator, keys ...string) {
+	ff.Sort(func(ln0, ln1 *Line) bool { return compareByKeys(ln0, ln1, keys, col) < 0 })
+}
+
+// SortStable sorts the lines of a flat file by the given less function,
+// keeping equal lines in their original order.
+func (ff *FlatFile) SortStable(less func(ln0, ln1 *Line) bool) {
+	ff.own()
+
+	sort.SliceStable(ff.lines, func(i, j int) bool { return less(ff.lines[i], ff.lines[j]) })
+}
+
+// SortByKeysStable sorts like SortByKeys, keeping records with equal
+// keys in their original order.
+func (ff *FlatFile) SortByKeysStable(keys ...string) {
+	ff.SortStable(func(ln0, ln1 *Line) bool { return compareByKeys(ln0, ln1, keys, nil) < 0 })
+}
+
+// IsSorted reports whether a flat file's lines are sorted by the given
+// less function.
+func (ff *FlatFile) IsSorted(less func(ln0, ln1 *Line) bool) bool {
+	return sort.SliceIsSorted(ff.lines, func(i, j int) bool { return less(ff.lines[i], ff.lines[j]) })
+}
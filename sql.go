@@ -0,0 +1,131 @@
+package flatfile
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// toDBBatch is the number of lines ToDB inserts per statement.
+const toDBBatch = 500
+
+// FromRows drains a SQL result set into a flat file under the given
+// layout, so generating an outbound fixed-width file from a query is one
+// call. Columns are matched to formats by key; columns naming no format
+// are ignored and formats without a column are left blank. NULL values
+// are left blank. Every value is converted to its string form and must
+// fit its format. FromRows closes nothing; the caller still owns rows.
+func FromRows(rows *sql.Rows, layout []Format) (*FlatFile, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	ff := New(StaticFormatter(layout...))
+	for rows.Next() {
+		vals := make([]sql.NullString, len(cols))
+		dest := make([]any, len(cols))
+		for i := range vals {
+			dest[i] = &vals[i]
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		m := make(map[string]string, len(cols))
+		for i, col := range cols {
+			if vals[i].Valid {
+				m[col] = vals[i].String
+			}
+		}
+
+		ln, err := NewLineFromMap(layout, m)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", ff.Len(), err)
+		}
+
+		ff.AppendLines(ln)
+	}
+
+	return ff, rows.Err()
+}
+
+// ToDB bulk-inserts a flat file's parsed fields into a database table,
+// completing the round trip with FromRows. The mapping names the column
+// each inserted field key loads into; every mapped key must exist on
+// every line. Lines are inserted in batches of multi-row statements
+// using ? placeholders, so drivers with other placeholder syntaxes need
+// a rewriting shim.
+func (ff *FlatFile) ToDB(ctx context.Context, db *sql.DB, table string, mapping map[string]string) error {
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	cols := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cols = append(cols, mapping[key])
+	}
+
+	var (
+		prefix = "INSERT INTO " + table + " (" + strings.Join(cols, ", ") + ") VALUES "
+		row    = "(" + strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ") + ")"
+	)
+
+	for start := 0; start < len(ff.lines); start += toDBBatch {
+		end := start + toDBBatch
+		if len(ff.lines) < end {
+			end = len(ff.lines)
+		}
+
+		var (
+			rows = make([]string, 0, end-start)
+			args = make([]any, 0, (end-start)*len(keys))
+		)
+
+		for i := start; i < end; i++ {
+			rows = append(rows, row)
+			for _, key := range keys {
+				v, err := ff.lines[i].Get(key)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", i, err)
+				}
+
+				args = append(args, v)
+			}
+		}
+
+		if _, err := db.ExecContext(ctx, prefix+strings.Join(rows, ", "), args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Scan implements sql.Scanner, parsing a raw fixed-width record stored
+// in a text or blob column against the line's existing formats. Build
+// the receiver with the right layout first — e.g. BuildLine(layout, nil)
+// — then scan into it.
+func (ln *Line) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return ln.SetRaw(v)
+	case []byte:
+		return ln.SetRaw(string(v))
+	default:
+		return fmt.Errorf("flatfile: cannot scan %T into a line", src)
+	}
+}
+
+// Value implements driver.Valuer, rendering a line as its raw
+// fixed-width text for storage in a text column.
+func (ln *Line) Value() (driver.Value, error) {
+	return ln.String(), nil
+}
@@ -0,0 +1,68 @@
+package flatfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Storage opens and creates named files in some backing store. The
+// package ships only DiskStorage; callers supply implementations backed
+// by S3, GCS, SFTP, or anything else without this package importing
+// their SDKs.
+type Storage interface {
+	// Open opens the named file for reading.
+	Open(name string) (io.ReadCloser, error)
+	// Create creates or truncates the named file for writing.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// DiskStorage is the local-disk Storage, resolving names relative to
+// Root, or to the working directory when Root is empty.
+type DiskStorage struct {
+	Root string
+}
+
+// Open opens the named file on disk.
+func (d DiskStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(d.Root, name))
+}
+
+// Create creates or truncates the named file on disk.
+func (d DiskStorage) Create(name string) (io.WriteCloser, error) {
+	return os.Create(filepath.Join(d.Root, name))
+}
+
+// ReadStorage reads and appends each line of a named file in a storage
+// backend, as ReadFile does for local disk. Each line records the name
+// and its 1-based line number as its source.
+func (ff *FlatFile) ReadStorage(s Storage, name string) error {
+	rc, err := s.Open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = ff.readFrom(rc, name)
+	if err == nil {
+		ff.markClean()
+	}
+
+	return err
+}
+
+// WriteStorage writes a flat file to a named file in a storage backend,
+// as WriteFile does for local disk.
+func (ff *FlatFile) WriteStorage(s Storage, name string) error {
+	wc, err := s.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ff.WriteTo(wc); err != nil {
+		wc.Close()
+		return err
+	}
+
+	return wc.Close()
+}
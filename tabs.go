@@ -0,0 +1,34 @@
+package flatfile
+
+import "strings"
+
+// WithTabWidth configures reads to expand tab characters to the next
+// multiple-of-width column stop before formats apply, since some
+// upstream tools emit tabs that silently shift every subsequent field.
+// A width under one leaves tabs alone. It returns the receiver for
+// chaining.
+func (ff *FlatFile) WithTabWidth(width int) *FlatFile {
+	ff.tabWidth = width
+	return ff
+}
+
+// expandTabs replaces each tab with the spaces reaching the next
+// multiple-of-width column.
+func expandTabs(line string, width int) string {
+	if width < 1 || !strings.Contains(line, "\t") {
+		return line
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(line))
+	for _, r := range line {
+		if r == '\t' {
+			sb.WriteString(strings.Repeat(" ", width-sb.Len()%width))
+			continue
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,22 @@
+package flatfile
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+)
+
+// RenderTemplate executes a template once per line, with the line's
+// key-to-value map as the data, so letters, emails, and EDI-ish
+// envelopes render directly from flat file records. A field's value is
+// referenced by key — {{.first}}, say. Errors name the line they came
+// from.
+func (ff *FlatFile) RenderTemplate(w io.Writer, tmpl *template.Template) error {
+	for i, ln := range ff.lines {
+		if err := tmpl.Execute(w, ln.KeyValues()); err != nil {
+			return fmt.Errorf("line %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package flatfile
+
+import "strconv"
+
+// FileStats summarizes a flat file's body for trailer generation.
+type FileStats struct {
+	Lines int                // body line count
+	Bytes int                // body byte length, including newlines
+	Sums  map[string]float64 // sum per numeric field, blanks skipped
+}
+
+// WithTrailer configures a trailer record generated from the body at
+// write time: the trailer line is laid out by the given formats and
+// filled from the values fill derives from the body's statistics. It
+// returns the receiver for chaining.
+func (ff *FlatFile) WithTrailer(layout []Format, fill func(stats FileStats) map[string]string) *FlatFile {
+	ff.trailerFmt = LineFmt(append([]Format(nil), layout...))
+	ff.trailerFill = fill
+	return ff
+}
+
+// stats computes the body statistics handed to a trailer fill function.
+func (ff *FlatFile) stats() FileStats {
+	st := FileStats{
+		Lines: len(ff.lines),
+		Bytes: ff.ByteLen(),
+		Sums:  make(map[string]float64),
+	}
+
+	for _, ln := range ff.lines {
+		for _, f := range ln.fields {
+			if f.jsonType != Num || len(f.value) == 0 {
+				continue
+			}
+
+			if x, err := strconv.ParseFloat(f.value, 64); err == nil {
+				st.Sums[f.key] += x
+			}
+		}
+	}
+
+	return st
+}
+
+// trailerLine builds the trailer record from the current body, or nil if
+// no trailer is configured.
+func (ff *FlatFile) trailerLine() (*Line, error) {
+	if ff.trailerFill == nil {
+		return nil, nil
+	}
+
+	return BuildLine(ff.trailerFmt, ff.trailerFill(ff.stats()))
+}
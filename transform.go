@@ -0,0 +1,138 @@
+package flatfile
+
+// ForEach calls fn with each line's index and a copy of the line.
+func (ff *FlatFile) ForEach(fn func(i int, ln *Line)) {
+	for i, ln := range ff.lines {
+		fn(i, ln.Copy())
+	}
+}
+
+// Map returns a new flat file holding the result of fn applied to a copy
+// of each line. Lines for which fn returns nil are dropped.
+func (ff *FlatFile) Map(fn func(ln *Line) *Line) *FlatFile {
+	mapped := New(ff.fmtr)
+	for _, ln := range ff.lines {
+		if mapped0 := fn(ln.Copy()); mapped0 != nil {
+			mapped.lines = append(mapped.lines, mapped0)
+		}
+	}
+
+	return mapped
+}
+
+// Where returns a view over the lines for which pred returns true. The
+// view shares line storage with the receiver — no lines are copied — and
+// supports the full read-only API. Either side copies its storage when
+// first mutated, so repeated queries against a loaded file stay cheap.
+func (ff *FlatFile) Where(pred func(ln *Line) bool) *FlatFile {
+	view := &FlatFile{
+		fmtr:   ff.fmtr,
+		shared: true,
+	}
+
+	for _, ln := range ff.lines {
+		if pred(ln) {
+			view.lines = append(view.lines, ln)
+		}
+	}
+
+	ff.shared = true
+	return view
+}
+
+// Dedupe removes duplicate records in place, keeping the first line
+// seen for each combination of the given key fields. With no key fields,
+// lines are compared by their full rendered content.
+func (ff *FlatFile) Dedupe(keys ...string) {
+	ff.own()
+
+	seen := make(map[string]bool, len(ff.lines))
+	kept := ff.lines[:0]
+	for _, ln := range ff.lines {
+		key := lineKey(ln, keys)
+		if !seen[key] {
+			seen[key] = true
+			kept = append(kept, ln)
+		}
+	}
+
+	ff.lines = kept
+}
+
+// DedupeLast removes duplicate records in place like Dedupe, but keeps
+// the last line seen for each key instead of the first.
+func (ff *FlatFile) DedupeLast(keys ...string) {
+	ff.own()
+
+	last := make(map[string]int, len(ff.lines))
+	for i, ln := range ff.lines {
+		last[lineKey(ln, keys)] = i
+	}
+
+	kept := ff.lines[:0]
+	for i, ln := range ff.lines {
+		if last[lineKey(ln, keys)] == i {
+			kept = append(kept, ln)
+		}
+	}
+
+	ff.lines = kept
+}
+
+// view returns a shared-storage view over a subrange of a flat file's
+// lines. Either side copies its storage when first mutated.
+func (ff *FlatFile) view(lns []*Line) *FlatFile {
+	ff.shared = true
+	return &FlatFile{
+		fmtr:   ff.fmtr,
+		lines:  lns,
+		shared: true,
+	}
+}
+
+// Head returns a view over the first n lines, or over every line if the
+// flat file holds fewer than n. A negative n is treated as zero.
+func (ff *FlatFile) Head(n int) *FlatFile {
+	if n < 0 {
+		n = 0
+	}
+
+	if len(ff.lines) < n {
+		n = len(ff.lines)
+	}
+
+	return ff.view(ff.lines[:n:n])
+}
+
+// Tail returns a view over the last n lines, or over every line if the
+// flat file holds fewer than n. A negative n is treated as zero.
+func (ff *FlatFile) Tail(n int) *FlatFile {
+	if n < 0 {
+		n = 0
+	}
+
+	if len(ff.lines) < n {
+		n = len(ff.lines)
+	}
+
+	return ff.view(ff.lines[len(ff.lines)-n:])
+}
+
+// Slice returns a view over lines i through j-1. The bounds follow Go
+// slice conventions and panic when out of range.
+func (ff *FlatFile) Slice(i, j int) *FlatFile {
+	return ff.view(ff.lines[i:j:j])
+}
+
+// Filter returns a new flat file holding a copy of each line for which
+// pred returns true.
+func (ff *FlatFile) Filter(pred func(ln *Line) bool) *FlatFile {
+	filtered := New(ff.fmtr)
+	for _, ln := range ff.lines {
+		if pred(ln) {
+			filtered.lines = append(filtered.lines, ln.Copy())
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,82 @@
+package flatfile
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Undo reverts the most recent tracked change and moves it to the redo
+// stack. Change tracking must be enabled with EnableAudit; only tracked
+// operations can be undone. It errors if there is nothing to undo.
+func (ff *FlatFile) Undo() error {
+	if len(ff.audit) == 0 {
+		return fmt.Errorf("flatfile: nothing to undo")
+	}
+
+	e := ff.audit[len(ff.audit)-1]
+	if err := ff.revert(e); err != nil {
+		return err
+	}
+
+	ff.audit = ff.audit[:len(ff.audit)-1]
+	ff.redo = append(ff.redo, e)
+	return nil
+}
+
+// Redo re-applies the most recently undone change and moves it back to
+// the audit log. The redo stack is cleared by any new tracked change. It
+// errors if there is nothing to redo.
+func (ff *FlatFile) Redo() error {
+	if len(ff.redo) == 0 {
+		return fmt.Errorf("flatfile: nothing to redo")
+	}
+
+	e := ff.redo[len(ff.redo)-1]
+	if err := ff.apply(e); err != nil {
+		return err
+	}
+
+	ff.redo = ff.redo[:len(ff.redo)-1]
+	ff.audit = append(ff.audit, e)
+	return nil
+}
+
+// revert undoes a single audit entry without recording.
+func (ff *FlatFile) revert(e AuditEntry) error {
+	ff.own()
+
+	switch e.Op {
+	case OpSet:
+		return ff.lines[e.Line].Set(e.Key, e.Old)
+	case OpAppend:
+		ff.lines = slices.Delete(ff.lines, e.Line, e.Line+1)
+		return nil
+	case OpRemove:
+		ff.lines = slices.Insert(ff.lines, e.Line, NewLine(ff.fmtr(e.Old), e.Old))
+		return nil
+	case OpReplace:
+		return ff.lines[e.Line].SetRaw(e.Old)
+	default:
+		return fmt.Errorf("flatfile: cannot undo operation %q", e.Op)
+	}
+}
+
+// apply re-applies a single audit entry without recording.
+func (ff *FlatFile) apply(e AuditEntry) error {
+	ff.own()
+
+	switch e.Op {
+	case OpSet:
+		return ff.lines[e.Line].Set(e.Key, e.New)
+	case OpAppend:
+		ff.lines = slices.Insert(ff.lines, e.Line, NewLine(ff.fmtr(e.New), e.New))
+		return nil
+	case OpRemove:
+		ff.lines = slices.Delete(ff.lines, e.Line, e.Line+1)
+		return nil
+	case OpReplace:
+		return ff.lines[e.Line].SetRaw(e.New)
+	default:
+		return fmt.Errorf("flatfile: cannot redo operation %q", e.Op)
+	}
+}
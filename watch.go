@@ -0,0 +1,74 @@
+package flatfile
+
+import (
+	"os"
+	"time"
+)
+
+// Watch polls the file at path at the given interval and reloads the
+// flat file whenever the file's size or modification time changes, for
+// services that serve reference data maintained as flat files. Polling
+// keeps the package free of platform-specific notification APIs. After
+// every reload attempt onReload, if non-nil, is called with the error
+// from the attempt, if any; a failed reload leaves the current lines in
+// place. Watch returns a stop function that ends the polling goroutine.
+func (sff *SafeFlatFile) Watch(path string, interval time.Duration, onReload func(err error)) (stop func(), err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+
+		size, mod := info.Size(), info.ModTime()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+
+			cur, err := os.Stat(path)
+			if err != nil {
+				if onReload != nil {
+					onReload(err)
+				}
+
+				continue
+			}
+
+			if cur.Size() == size && cur.ModTime().Equal(mod) {
+				continue
+			}
+
+			size, mod = cur.Size(), cur.ModTime()
+			err = sff.reload(path)
+			if onReload != nil {
+				onReload(err)
+			}
+		}
+	}()
+
+	return func() { close(done) }, nil
+}
+
+// reload reads the file at path into a fresh flat file and swaps it in,
+// so readers never observe a partially loaded file.
+func (sff *SafeFlatFile) reload(path string) error {
+	sff.mu.RLock()
+	fresh := New(sff.ff.fmtr)
+	sff.mu.RUnlock()
+
+	if err := fresh.ReadFile(path); err != nil {
+		return err
+	}
+
+	sff.mu.Lock()
+	defer sff.mu.Unlock()
+
+	sff.ff = fresh
+	return nil
+}
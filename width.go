@@ -0,0 +1,68 @@
+package flatfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A WidthPolicy selects what WriteTo does with a rendered line that
+// misses the enforced record length.
+type WidthPolicy byte
+
+const (
+	// WidthError rejects the write with an error naming the line.
+	WidthError WidthPolicy = iota
+	// WidthFix pads short lines with trailing spaces and truncates long
+	// ones to the record length.
+	WidthFix
+)
+
+// WithRecordLength configures WriteTo — and the file writers built on
+// it — to enforce that every rendered line, trailer included, is
+// exactly length bytes, applying the given policy to lines that miss
+// it, so malformed records are caught before a partner's loader bounces
+// the file. A length under one disables enforcement. It returns the
+// receiver for chaining.
+func (ff *FlatFile) WithRecordLength(length int, policy WidthPolicy) *FlatFile {
+	ff.recordLen = length
+	ff.widthPolicy = policy
+	return ff
+}
+
+// WithWidthMode selects the width mode for every current line and for
+// lines appended later, so one call switches a whole file between
+// byte-for-byte fidelity and extent-exact output. It returns the
+// receiver for chaining.
+func (ff *FlatFile) WithWidthMode(m WidthMode) *FlatFile {
+	ff.own()
+
+	ff.widthMode = m
+	for _, ln := range ff.lines {
+		ln.widthMode = m
+	}
+
+	return ff
+}
+
+// enforceWidth applies the record length policy to a rendered line,
+// where i names the line in errors and -1 names the trailer.
+func (ff *FlatFile) enforceWidth(s string, i int) (string, error) {
+	if ff.recordLen < 1 || len(s) == ff.recordLen {
+		return s, nil
+	}
+
+	if ff.widthPolicy == WidthFix {
+		if len(s) < ff.recordLen {
+			return s + strings.Repeat(" ", ff.recordLen-len(s)), nil
+		}
+
+		return s[:ff.recordLen], nil
+	}
+
+	name := fmt.Sprintf("line %d", i)
+	if i < 0 {
+		name = "trailer"
+	}
+
+	return "", fmt.Errorf("flatfile: %s renders %d bytes, want %d", name, len(s), ff.recordLen)
+}